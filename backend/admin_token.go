@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	adminTokenIssuer   = "nextjs-go-admin-portfolio-web"
+	adminTokenAudience = "nextjs-go-admin-portfolio-backend"
+	// 有効期限の長すぎるトークンは受け付けない
+	adminTokenMaxLifetime = 10 * time.Minute
+	adminContextKey       = "admin"
+)
+
+type adminIdentity struct {
+	Email string
+}
+
+func parseAllowedEmails(raw string) []string {
+	emails := make([]string, 0)
+	for _, email := range strings.Split(raw, ",") {
+		normalized := strings.ToLower(strings.TrimSpace(email))
+		if normalized != "" {
+			emails = append(emails, normalized)
+		}
+	}
+	return emails
+}
+
+func isAllowedAdminEmail(allowedEmails []string, email string) bool {
+	normalized := strings.ToLower(strings.TrimSpace(email))
+	if normalized == "" {
+		return false
+	}
+	allowed := 0
+	for _, candidate := range allowedEmails {
+		allowed |= subtle.ConstantTimeCompare([]byte(candidate), []byte(normalized))
+	}
+	return allowed == 1
+}
+
+func (pSrv *server) verifyAdminToken(rawToken string) (*adminIdentity, error) {
+	claims := &jwt.StandardClaims{}
+	token, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(pSrv.adminSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid admin token")
+	}
+
+	if claims.ExpiresAt == 0 || claims.IssuedAt == 0 {
+		return nil, errors.New("admin token must have exp and iat")
+	}
+	if time.Duration(claims.ExpiresAt-claims.IssuedAt)*time.Second > adminTokenMaxLifetime {
+		return nil, errors.New("admin token lifetime is too long")
+	}
+	if !claims.VerifyIssuer(adminTokenIssuer, true) || !claims.VerifyAudience(adminTokenAudience, true) {
+		return nil, errors.New("admin token issuer or audience mismatch")
+	}
+	if !isAllowedAdminEmail(pSrv.adminAllowedEmails, claims.Subject) {
+		return nil, errors.New("admin email is not allowed")
+	}
+
+	return &adminIdentity{Email: strings.ToLower(strings.TrimSpace(claims.Subject))}, nil
+}
+
+func bearerToken(c echo.Context) string {
+	header := strings.TrimSpace(c.Request().Header.Get(echo.HeaderAuthorization))
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[len("Bearer "):])
+}
+
+func adminFromContext(c echo.Context) *adminIdentity {
+	admin, _ := c.Get(adminContextKey).(*adminIdentity)
+	return admin
+}
+
+func adminEmail(c echo.Context) string {
+	if admin := adminFromContext(c); admin != nil {
+		return admin.Email
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APIの利用者が機械的に判別できるよう、エラーは安定したコードとともに返す
+type apiError struct {
+	status  int
+	code    string
+	message string
+	details interface{}
+	cause   error
+}
+
+type errorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"request_id"`
+	Details   interface{} `json:"details"`
+}
+
+type parameterErrorDetails struct {
+	Parameter string `json:"parameter"`
+}
+
+func (e *apiError) Error() string {
+	if e.cause != nil {
+		return e.code + ": " + e.message + ": " + e.cause.Error()
+	}
+	return e.code + ": " + e.message
+}
+
+func (e *apiError) Unwrap() error {
+	return e.cause
+}
+
+func newAPIError(status int, code string, message string) *apiError {
+	return &apiError{
+		status:  status,
+		code:    code,
+		message: message,
+	}
+}
+
+func (e *apiError) withDetails(details interface{}) *apiError {
+	e.details = details
+	return e
+}
+
+func (e *apiError) withCause(err error) *apiError {
+	e.cause = err
+	return e
+}
+
+func invalidParameter(parameter string, message string) *apiError {
+	return newAPIError(http.StatusBadRequest, "invalid_parameter", message).
+		withDetails(parameterErrorDetails{Parameter: parameter})
+}
+
+func invalidRequestBody() *apiError {
+	return newAPIError(http.StatusBadRequest, "invalid_request_body", "invalid request body")
+}
+
+func notFound(code string, message string) *apiError {
+	return newAPIError(http.StatusNotFound, code, message)
+}
+
+func internalError(message string, err error) *apiError {
+	return newAPIError(http.StatusInternalServerError, "internal_error", message).withCause(err)
+}
+
+func httpErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "too_many_requests"
+	case http.StatusServiceUnavailable:
+		return "service_unavailable"
+	}
+	if status >= http.StatusInternalServerError {
+		return "internal_error"
+	}
+	return "http_error"
+}
+
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message := http.StatusText(httpErr.Code)
+		if text, ok := httpErr.Message.(string); ok && text != "" {
+			message = text
+		}
+		return newAPIError(httpErr.Code, httpErrorCode(httpErr.Code), message).withCause(httpErr.Internal)
+	}
+
+	return internalError("internal server error", err)
+}
+
+func (pSrv *server) handleHTTPError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiErr := toAPIError(err)
+	if apiErr.status >= http.StatusInternalServerError {
+		log.Printf("[error] %s %s: %v", c.Request().Method, c.Path(), err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.status)
+	} else {
+		err = c.JSON(apiErr.status, errorResponse{
+			Code:      apiErr.code,
+			Message:   apiErr.message,
+			RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+			Details:   apiErr.details,
+		})
+	}
+	if err != nil {
+		log.Printf("[error] failed to write error response: %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	auditTargetIDKey   = "audit_target_id"
+	auditBeforeKey     = "audit_before"
+	auditAfterKey      = "audit_after"
+	auditMaxBodyLength = 16 << 10 // 16 KiB
+)
+
+type auditLogEntry struct {
+	ID             string          `json:"id"`
+	Actor          string          `json:"actor"`
+	Method         string          `json:"method"`
+	Route          string          `json:"route"`
+	TargetID       *string         `json:"target_id"`
+	RequestSummary json.RawMessage `json:"request_summary"`
+	Status         int             `json:"status"`
+	BeforeSnapshot json.RawMessage `json:"before_snapshot"`
+	AfterSnapshot  json.RawMessage `json:"after_snapshot"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type auditLogPageResponse struct {
+	Items      []auditLogEntry `json:"items"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+type auditRequestSummary struct {
+	Query         string          `json:"query,omitempty"`
+	ContentType   string          `json:"content_type,omitempty"`
+	ContentLength int64           `json:"content_length,omitempty"`
+	Body          json.RawMessage `json:"body,omitempty"`
+	BodyTruncated bool            `json:"body_truncated,omitempty"`
+}
+
+func setAuditTargetID(c echo.Context, targetID string) {
+	c.Set(auditTargetIDKey, targetID)
+}
+
+func setAuditBefore(c echo.Context, snapshot interface{}) {
+	c.Set(auditBeforeKey, snapshot)
+}
+
+func setAuditAfter(c echo.Context, snapshot interface{}) {
+	c.Set(auditAfterKey, snapshot)
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+// JSONボディのみ記録し、アップロードファイルなどは種類とサイズだけ残す
+func summarizeAuditRequest(c echo.Context) auditRequestSummary {
+	req := c.Request()
+	summary := auditRequestSummary{
+		Query:         req.URL.RawQuery,
+		ContentType:   req.Header.Get(echo.HeaderContentType),
+		ContentLength: req.ContentLength,
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(summary.ContentType, ";")[0])
+	if req.Body == nil || (mediaType != echo.MIMEApplicationJSON && !strings.HasSuffix(mediaType, "+json")) {
+		return summary
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, auditMaxBodyLength+1))
+	if err != nil {
+		return summary
+	}
+	req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), req.Body))
+
+	if len(body) > auditMaxBodyLength {
+		summary.BodyTruncated = true
+		return summary
+	}
+	if json.Valid(body) {
+		summary.Body = body
+	}
+
+	return summary
+}
+
+func marshalAuditSnapshot(c echo.Context, key string) []byte {
+	snapshot := c.Get(key)
+	if snapshot == nil {
+		return nil
+	}
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil
+	}
+	return payload
+}
+
+func (pSrv *server) auditAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !isMutatingMethod(c.Request().Method) {
+			return next(c)
+		}
+
+		summary := summarizeAuditRequest(c)
+		err := next(c)
+
+		status := c.Response().Status
+		if err != nil && !c.Response().Committed {
+			status = toAPIError(err).status
+		}
+
+		pSrv.recordAuditLog(c, status, summary)
+		return err
+	}
+}
+
+func (pSrv *server) recordAuditLog(c echo.Context, status int, summary auditRequestSummary) {
+	actor := "unknown"
+	if admin := adminFromContext(c); admin != nil {
+		actor = admin.Email
+	}
+
+	var targetID *string
+	if id, ok := c.Get(auditTargetIDKey).(string); ok && id != "" {
+		targetID = &id
+	} else if id := strings.TrimSpace(c.Param("id")); id != "" {
+		targetID = &id
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		summaryJSON = nil
+	}
+
+	// レスポンス後も記録を続けるためリクエストのキャンセルは引き継がない
+	ctx := context.WithoutCancel(c.Request().Context())
+	err = pSrv.db.WithContext(ctx).Exec(
+		`
+		INSERT INTO admin_audit_log (
+			actor,
+			method,
+			route,
+			target_id,
+			request_summary,
+			status,
+			before_snapshot,
+			after_snapshot
+		) VALUES (?, ?, ?, ?, ?::jsonb, ?, ?::jsonb, ?::jsonb)
+		`,
+		actor,
+		c.Request().Method,
+		c.Path(),
+		targetID,
+		nullableJSON(summaryJSON),
+		status,
+		nullableJSON(marshalAuditSnapshot(c, auditBeforeKey)),
+		nullableJSON(marshalAuditSnapshot(c, auditAfterKey)),
+	).Error
+	if err != nil {
+		log.Printf("[audit] failed to record %s %s: %v", c.Request().Method, c.Path(), err)
+	}
+}
+
+func nullableJSON(payload []byte) *string {
+	if len(payload) == 0 {
+		return nil
+	}
+	value := string(payload)
+	return &value
+}
+
+func (pSrv *server) handleGetAuditLogs(c echo.Context) error {
+	limit, err := parsePageLimit(c.QueryParam("limit"), 50, 200)
+	if err != nil {
+		return invalidParameter("limit", err.Error())
+	}
+
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if actor := strings.ToLower(strings.TrimSpace(c.QueryParam("actor"))); actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, actor)
+	}
+	if targetID := strings.TrimSpace(c.QueryParam("target_id")); targetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, targetID)
+	}
+	if method := strings.ToUpper(strings.TrimSpace(c.QueryParam("method"))); method != "" {
+		conditions = append(conditions, "method = ?")
+		args = append(args, method)
+	}
+	if route := strings.TrimSpace(c.QueryParam("route")); route != "" {
+		conditions = append(conditions, "route LIKE ?")
+		args = append(args, route+"%")
+	}
+	if rawStatus := strings.TrimSpace(c.QueryParam("status")); rawStatus != "" {
+		status, err := strconv.Atoi(rawStatus)
+		if err != nil {
+			return invalidParameter("status", "status must be number")
+		}
+		conditions = append(conditions, "status = ?")
+		args = append(args, status)
+	}
+	if rawFrom := strings.TrimSpace(c.QueryParam("from")); rawFrom != "" {
+		from, err := parseAnalyticsTime(rawFrom, false)
+		if err != nil {
+			return invalidParameter("from", "from must be formatted as YYYY-MM-DD or RFC3339")
+		}
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, from)
+	}
+	if rawTo := strings.TrimSpace(c.QueryParam("to")); rawTo != "" {
+		to, err := parseAnalyticsTime(rawTo, true)
+		if err != nil {
+			return invalidParameter("to", "to must be formatted as YYYY-MM-DD or RFC3339")
+		}
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, to)
+	}
+	if rawCursor := strings.TrimSpace(c.QueryParam("cursor")); rawCursor != "" {
+		cursor, err := decodePageCursor(rawCursor)
+		if err != nil {
+			return invalidParameter("cursor", "cursor is invalid")
+		}
+		conditions = append(conditions, "(created_at, id) < (?, ?::uuid)")
+		args = append(args, cursor.At, cursor.ID)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit+1)
+
+	var entries []auditLogEntry
+	err = pSrv.db.WithContext(c.Request().Context()).Raw(
+		`
+		SELECT
+			id,
+			actor,
+			method,
+			route,
+			target_id,
+			request_summary,
+			status,
+			before_snapshot,
+			after_snapshot,
+			created_at
+		FROM admin_audit_log
+		`+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+		`,
+		args...,
+	).Scan(&entries).Error
+	if err != nil {
+		return internalError("failed to fetch audit logs", err)
+	}
+
+	var nextCursor *string
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[len(entries)-1]
+		cursor := encodePageCursor(last.CreatedAt, last.ID)
+		nextCursor = &cursor
+	}
+	if entries == nil {
+		entries = []auditLogEntry{}
+	}
+
+	return c.JSON(http.StatusOK, auditLogPageResponse{
+		Items:      entries,
+		NextCursor: nextCursor,
+	})
+}
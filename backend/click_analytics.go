@@ -0,0 +1,386 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	analyticsDefaultRange = 30 * 24 * time.Hour
+	analyticsMaxBuckets   = 2000
+)
+
+type clickSeriesPoint struct {
+	Bucket string `json:"bucket"`
+	Clicks int64  `json:"clicks"`
+}
+
+type clickSeriesResponse struct {
+	WorkID string             `json:"work_id"`
+	Title  string             `json:"title"`
+	Total  int64              `json:"total"`
+	Points []clickSeriesPoint `json:"points"`
+}
+
+type clickTotalEntry struct {
+	WorkID string `json:"work_id"`
+	Title  string `json:"title"`
+	Clicks int64  `json:"clicks"`
+}
+
+type clickTotalsResponse struct {
+	From  string            `json:"from"`
+	To    string            `json:"to"`
+	Total int64             `json:"total"`
+	Works []clickTotalEntry `json:"works"`
+}
+
+type clickMoverEntry struct {
+	WorkID         string   `json:"work_id"`
+	Title          string   `json:"title"`
+	CurrentClicks  int64    `json:"current_clicks"`
+	PreviousClicks int64    `json:"previous_clicks"`
+	Delta          int64    `json:"delta"`
+	ChangeRate     *float64 `json:"change_rate"`
+}
+
+type clickMoversResponse struct {
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	PreviousFrom string            `json:"previous_from"`
+	PreviousTo   string            `json:"previous_to"`
+	Works        []clickMoverEntry `json:"works"`
+}
+
+// 日付のみ指定された場合は to をその日の終わりまで含める
+func parseAnalyticsTime(raw string, endOfDay bool) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return parsed.UTC(), nil
+	}
+	parsed, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return parsed.UTC(), nil
+}
+
+func parseAnalyticsRange(c echo.Context) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if rawTo := strings.TrimSpace(c.QueryParam("to")); rawTo != "" {
+		parsed, err := parseAnalyticsTime(rawTo, true)
+		if err != nil {
+			return time.Time{}, time.Time{}, invalidParameter("to", "to must be formatted as YYYY-MM-DD or RFC3339")
+		}
+		to = parsed
+	}
+
+	from := to.Add(-analyticsDefaultRange)
+	if rawFrom := strings.TrimSpace(c.QueryParam("from")); rawFrom != "" {
+		parsed, err := parseAnalyticsTime(rawFrom, false)
+		if err != nil {
+			return time.Time{}, time.Time{}, invalidParameter("from", "from must be formatted as YYYY-MM-DD or RFC3339")
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, invalidParameter("from", "from must be before to")
+	}
+
+	return from, to, nil
+}
+
+func truncateClickBucket(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	switch bucket {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// PostgreSQLのdate_truncと同じく月曜始まり
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// 日次ロールアップを参照するため、範囲を日単位に広げる
+func clickDayRange(from time.Time, to time.Time) (string, string) {
+	fromDay := truncateClickBucket(from, "day")
+	toDay := truncateClickBucket(to.Add(-time.Nanosecond), "day").AddDate(0, 0, 1)
+	return fromDay.Format("2006-01-02"), toDay.Format("2006-01-02")
+}
+
+func nextClickBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case "hour":
+		return t.Add(time.Hour)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func (pSrv *server) handleGetClickSeries(c echo.Context) error {
+	bucket := strings.TrimSpace(c.QueryParam("bucket"))
+	if bucket == "" {
+		bucket = "day"
+	}
+	if bucket != "hour" && bucket != "day" && bucket != "week" {
+		return invalidParameter("bucket", "bucket must be one of hour, day, week")
+	}
+
+	from, to, err := parseAnalyticsRange(c)
+	if err != nil {
+		return err
+	}
+
+	buckets := make([]time.Time, 0)
+	for t := truncateClickBucket(from, bucket); t.Before(to); t = nextClickBucket(t, bucket) {
+		buckets = append(buckets, t)
+		if len(buckets) > analyticsMaxBuckets {
+			return invalidParameter("bucket", "too many buckets for the requested range")
+		}
+	}
+
+	workID := strings.TrimSpace(c.QueryParam("work_id"))
+
+	type row struct {
+		WorkID string    `gorm:"column:work_id"`
+		Title  string    `gorm:"column:title"`
+		Bucket time.Time `gorm:"column:bucket"`
+		Clicks int64     `gorm:"column:clicks"`
+	}
+
+	var rows []row
+	if bucket == "hour" {
+		// 時間単位は保持期間内の生クリックのみから集計する
+		condition := "c.clicked_at >= ? AND c.clicked_at < ?"
+		args := []interface{}{from, to}
+		if workID != "" {
+			condition += " AND c.work_id = ?"
+			args = append(args, workID)
+		}
+		err = pSrv.db.WithContext(c.Request().Context()).Raw(
+			`
+			SELECT
+				c.work_id,
+				w.title,
+				date_trunc('hour', c.clicked_at AT TIME ZONE 'UTC') AS bucket,
+				COUNT(*) AS clicks
+			FROM isirmt_work_clicks c
+			JOIN isirmt_works w ON w.id = c.work_id
+			WHERE `+condition+`
+			GROUP BY c.work_id, w.title, bucket
+			ORDER BY c.work_id, bucket
+			`,
+			args...,
+		).Scan(&rows).Error
+	} else {
+		fromDay, toDay := clickDayRange(from, to)
+		condition := "c.day >= ?::date AND c.day < ?::date"
+		args := []interface{}{bucket, fromDay, toDay}
+		if workID != "" {
+			condition += " AND c.work_id = ?"
+			args = append(args, workID)
+		}
+		err = pSrv.db.WithContext(c.Request().Context()).Raw(
+			`
+			WITH daily_clicks AS (`+workDailyClicksSQL+`)
+			SELECT
+				c.work_id,
+				w.title,
+				date_trunc(?, c.day::timestamp) AS bucket,
+				SUM(c.click_count) AS clicks
+			FROM daily_clicks c
+			JOIN isirmt_works w ON w.id = c.work_id
+			WHERE `+condition+`
+			GROUP BY c.work_id, w.title, bucket
+			ORDER BY c.work_id, bucket
+			`,
+			args...,
+		).Scan(&rows).Error
+	}
+	if err != nil {
+		return internalError("failed to fetch click series", err)
+	}
+
+	type seriesEntry struct {
+		title  string
+		counts map[int64]int64
+	}
+	order := make([]string, 0)
+	entries := map[string]*seriesEntry{}
+	for _, row := range rows {
+		entry, ok := entries[row.WorkID]
+		if !ok {
+			entry = &seriesEntry{title: row.Title, counts: map[int64]int64{}}
+			entries[row.WorkID] = entry
+			order = append(order, row.WorkID)
+		}
+		entry.counts[truncateClickBucket(row.Bucket, bucket).Unix()] += row.Clicks
+	}
+
+	responses := make([]clickSeriesResponse, 0, len(order))
+	for _, id := range order {
+		entry := entries[id]
+		points := make([]clickSeriesPoint, 0, len(buckets))
+		var total int64
+		for _, t := range buckets {
+			clicks := entry.counts[t.Unix()]
+			total += clicks
+			points = append(points, clickSeriesPoint{
+				Bucket: t.Format(time.RFC3339),
+				Clicks: clicks,
+			})
+		}
+		responses = append(responses, clickSeriesResponse{
+			WorkID: id,
+			Title:  entry.title,
+			Total:  total,
+			Points: points,
+		})
+	}
+
+	return c.JSON(http.StatusOK, responses)
+}
+
+func (pSrv *server) handleGetClickTotals(c echo.Context) error {
+	from, to, err := parseAnalyticsRange(c)
+	if err != nil {
+		return err
+	}
+
+	// 集計済みの日は正午のクリックとして数える
+	var rows []clickTotalEntry
+	err = pSrv.db.WithContext(c.Request().Context()).Raw(
+		`
+		WITH daily_clicks AS (`+workDailyClicksSQL+`)
+		SELECT
+			c.work_id,
+			w.title,
+			SUM(c.click_count) AS clicks
+		FROM daily_clicks c
+		JOIN isirmt_works w ON w.id = c.work_id
+		WHERE c.clicked_at >= ? AND c.clicked_at < ?
+		GROUP BY c.work_id, w.title
+		ORDER BY clicks DESC, c.work_id
+		`,
+		from,
+		to,
+	).Scan(&rows).Error
+	if err != nil {
+		return internalError("failed to fetch click totals", err)
+	}
+
+	var total int64
+	for _, row := range rows {
+		total += row.Clicks
+	}
+	if rows == nil {
+		rows = []clickTotalEntry{}
+	}
+
+	return c.JSON(http.StatusOK, clickTotalsResponse{
+		From:  from.Format(time.RFC3339),
+		To:    to.Format(time.RFC3339),
+		Total: total,
+		Works: rows,
+	})
+}
+
+func (pSrv *server) handleGetClickMovers(c echo.Context) error {
+	from, to, err := parseAnalyticsRange(c)
+	if err != nil {
+		return err
+	}
+
+	limit, err := parsePageLimit(c.QueryParam("limit"), 10, 100)
+	if err != nil {
+		return invalidParameter("limit", err.Error())
+	}
+
+	orderDirection := "DESC"
+	switch direction := strings.TrimSpace(c.QueryParam("direction")); direction {
+	case "", "up":
+	case "down":
+		orderDirection = "ASC"
+	default:
+		return invalidParameter("direction", "direction must be up or down")
+	}
+
+	// 直前の同じ長さの期間と比較する
+	previousFrom := from.Add(-to.Sub(from))
+
+	type row struct {
+		WorkID         string `gorm:"column:work_id"`
+		Title          string `gorm:"column:title"`
+		CurrentClicks  int64  `gorm:"column:current_clicks"`
+		PreviousClicks int64  `gorm:"column:previous_clicks"`
+	}
+
+	// 集計済みの日は正午のクリックとして数える
+	var rows []row
+	err = pSrv.db.WithContext(c.Request().Context()).Raw(
+		`
+		WITH daily_clicks AS (`+workDailyClicksSQL+`),
+		compared AS (
+			SELECT
+				w.id AS work_id,
+				w.title,
+				COALESCE(SUM(c.click_count) FILTER (WHERE c.clicked_at >= ?), 0) AS current_clicks,
+				COALESCE(SUM(c.click_count) FILTER (WHERE c.clicked_at < ?), 0) AS previous_clicks
+			FROM isirmt_works w
+			LEFT JOIN daily_clicks c
+				ON c.work_id = w.id
+				AND c.clicked_at >= ?
+				AND c.clicked_at < ?
+			WHERE w.deleted_at IS NULL
+			GROUP BY w.id, w.title
+		)
+		SELECT work_id, title, current_clicks, previous_clicks
+		FROM compared
+		ORDER BY current_clicks - previous_clicks `+orderDirection+`, work_id
+		LIMIT ?
+		`,
+		from,
+		from,
+		previousFrom,
+		to,
+		limit,
+	).Scan(&rows).Error
+	if err != nil {
+		return internalError("failed to fetch click movers", err)
+	}
+
+	movers := make([]clickMoverEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := clickMoverEntry{
+			WorkID:         row.WorkID,
+			Title:          row.Title,
+			CurrentClicks:  row.CurrentClicks,
+			PreviousClicks: row.PreviousClicks,
+			Delta:          row.CurrentClicks - row.PreviousClicks,
+		}
+		if row.PreviousClicks > 0 {
+			rate := float64(entry.Delta) / float64(row.PreviousClicks)
+			entry.ChangeRate = &rate
+		}
+		movers = append(movers, entry)
+	}
+
+	return c.JSON(http.StatusOK, clickMoversResponse{
+		From:         from.Format(time.RFC3339),
+		To:           to.Format(time.RFC3339),
+		PreviousFrom: previousFrom.Format(time.RFC3339),
+		PreviousTo:   from.Format(time.RFC3339),
+		Works:        movers,
+	})
+}
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
+	"log"
 	"sync"
 	"time"
+
+	"gorm.io/gorm"
 )
 
-type clickLimiter struct {
+type clickLimiter interface {
+	isAllowedClick(ctx context.Context, ip, workID string) (bool, error)
+}
+
+func clickLimiterKey(ip, workID string) string {
+	return ip + "|" + workID
+}
+
+type memoryClickLimiter struct {
 	mu              sync.Mutex
 	lastClicks      map[string]time.Time
 	minInterval     time.Duration
@@ -14,8 +26,8 @@ type clickLimiter struct {
 	lastCleanup     time.Time
 }
 
-func createClickLimiter(minInterval time.Duration, maxEntries int, cleanupInterval time.Duration) *clickLimiter {
-	return &clickLimiter{
+func createMemoryClickLimiter(minInterval time.Duration, maxEntries int, cleanupInterval time.Duration) *memoryClickLimiter {
+	return &memoryClickLimiter{
 		lastClicks:      make(map[string]time.Time),
 		minInterval:     minInterval,
 		maxEntries:      maxEntries,
@@ -24,19 +36,19 @@ func createClickLimiter(minInterval time.Duration, maxEntries int, cleanupInterv
 	}
 }
 
-func (l *clickLimiter) isAllowedClick(ip, workID string) bool {
+func (l *memoryClickLimiter) isAllowedClick(_ context.Context, ip, workID string) (bool, error) {
 	if l == nil {
-		return true
+		return true, nil
 	}
 
 	now := time.Now()
-	key := ip + "|" + workID
+	key := clickLimiterKey(ip, workID)
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	if last, ok := l.lastClicks[key]; ok && now.Sub(last) < l.minInterval {
-		return false
+		return false, nil
 	}
 	l.lastClicks[key] = now
 
@@ -50,5 +62,80 @@ func (l *clickLimiter) isAllowedClick(ip, workID string) bool {
 		l.lastCleanup = now
 	}
 
-	return true
+	return true, nil
+}
+
+type postgresClickLimiter struct {
+	db              *gorm.DB
+	minInterval     time.Duration
+	cleanupInterval time.Duration
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+func createPostgresClickLimiter(db *gorm.DB, minInterval time.Duration, cleanupInterval time.Duration) *postgresClickLimiter {
+	return &postgresClickLimiter{
+		db:              db,
+		minInterval:     minInterval,
+		cleanupInterval: cleanupInterval,
+		lastCleanup:     time.Now(),
+	}
+}
+
+func (l *postgresClickLimiter) isAllowedClick(ctx context.Context, ip, workID string) (bool, error) {
+	if l == nil {
+		return true, nil
+	}
+
+	// 間隔内の再クリックは ON CONFLICT の WHERE で更新されず、行が返らない
+	var accepted int64
+	err := l.db.WithContext(ctx).Raw(
+		`
+		WITH upserted AS (
+			INSERT INTO isirmt_work_click_limits (limit_key, last_clicked_at)
+			VALUES (?, NOW())
+			ON CONFLICT (limit_key) DO UPDATE SET last_clicked_at = EXCLUDED.last_clicked_at
+			WHERE isirmt_work_click_limits.last_clicked_at <= NOW() - make_interval(secs => ?)
+			RETURNING limit_key
+		)
+		SELECT COUNT(*) FROM upserted
+		`,
+		clickLimiterKey(ip, workID),
+		l.minInterval.Seconds(),
+	).Scan(&accepted).Error
+	if err != nil {
+		return false, err
+	}
+
+	l.sweep(ctx)
+
+	return accepted > 0, nil
+}
+
+func (l *postgresClickLimiter) sweep(ctx context.Context) {
+	l.mu.Lock()
+	if time.Since(l.lastCleanup) < l.cleanupInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.lastCleanup = time.Now()
+	l.mu.Unlock()
+
+	_ = l.db.WithContext(ctx).Exec(
+		`DELETE FROM isirmt_work_click_limits WHERE last_clicked_at < NOW() - make_interval(secs => ?)`,
+		(l.minInterval * 10).Seconds(),
+	).Error
+}
+
+func createClickLimiterFromEnv(db *gorm.DB) clickLimiter {
+	switch backend := getEnv("CLICK_LIMITER_BACKEND", "memory"); backend {
+	case "postgres":
+		return createPostgresClickLimiter(db, 2*time.Second, time.Minute)
+	case "memory":
+		return createMemoryClickLimiter(2*time.Second, 10000, time.Minute)
+	default:
+		log.Fatalf("CLICK_LIMITER_BACKEND must be memory or postgres. got %q", backend)
+		return nil
+	}
 }
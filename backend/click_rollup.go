@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 日付の境界直後に確定前のクリックを取りこぼさないための猶予
+	clickRollupGrace = 10 * time.Minute
+	// 複数インスタンスで同時に集計しないためのadvisory lockキー
+	clickRollupLockKey = 7_201_001
+)
+
+// 集計済みの日次ロールアップと、まだ集計されていない生クリックを合わせたもの
+// clicked_atは、集計済みの日はその日の正午、未集計のクリックは実際のクリック時刻
+const workDailyClicksSQL = `
+	SELECT d.work_id, d.day, d.day::timestamp AT TIME ZONE 'UTC' + INTERVAL '12 hours' AS clicked_at, d.click_count
+	FROM isirmt_work_click_daily d
+	WHERE d.day < (SELECT COALESCE(MAX(rolled_until), '-infinity'::date) FROM isirmt_work_click_rollup_state)
+	UNION ALL
+	SELECT c.work_id, (c.clicked_at AT TIME ZONE 'UTC')::date AS day, c.clicked_at, 1 AS click_count
+	FROM isirmt_work_clicks c
+	WHERE c.clicked_at >= (SELECT COALESCE(MAX(rolled_until), '-infinity'::date) FROM isirmt_work_click_rollup_state)::timestamp AT TIME ZONE 'UTC'
+`
+
+func (pSrv *server) startClickRollup(ctx context.Context, interval time.Duration, retention time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := pSrv.rollupWorkClicks(ctx, retention); err != nil {
+				log.Printf("[click rollup] %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (pSrv *server) rollupWorkClicks(ctx context.Context, retention time.Duration) error {
+	now := time.Now().UTC()
+	cutoff := truncateClickBucket(now.Add(-clickRollupGrace), "day")
+
+	return pSrv.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw(`SELECT pg_try_advisory_xact_lock(?)`, clickRollupLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var state sql.NullTime
+		if err := tx.Raw(`SELECT MAX(rolled_until) FROM isirmt_work_click_rollup_state`).Scan(&state).Error; err != nil {
+			return err
+		}
+
+		rolledUntil := state.Time
+		if !state.Valid || rolledUntil.Before(cutoff) {
+			// 初回は全期間を集計する
+			from := time.Time{}
+			if state.Valid {
+				from = rolledUntil
+			}
+
+			if err := tx.Exec(
+				`
+				INSERT INTO isirmt_work_click_daily (work_id, day, click_count)
+				SELECT
+					work_id,
+					(clicked_at AT TIME ZONE 'UTC')::date AS day,
+					COUNT(*)
+				FROM isirmt_work_clicks
+				WHERE clicked_at >= ? AND clicked_at < ?
+				GROUP BY work_id, day
+				ON CONFLICT (work_id, day) DO UPDATE SET click_count = EXCLUDED.click_count
+				`,
+				from,
+				cutoff,
+			).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Exec(
+				`
+				INSERT INTO isirmt_work_click_rollup_state (id, rolled_until, updated_at)
+				VALUES (1, ?::date, NOW())
+				ON CONFLICT (id) DO UPDATE SET rolled_until = EXCLUDED.rolled_until, updated_at = NOW()
+				`,
+				cutoff.Format("2006-01-02"),
+			).Error; err != nil {
+				return err
+			}
+			rolledUntil = cutoff
+		}
+
+		if retention <= 0 {
+			return nil
+		}
+
+		// 集計済みの日に限り保持期間を過ぎた生クリックを削除する
+		deleteBefore := now.Add(-retention)
+		if rolledUntil.Before(deleteBefore) {
+			deleteBefore = rolledUntil
+		}
+		result := tx.Exec(`DELETE FROM isirmt_work_clicks WHERE clicked_at < ?`, deleteBefore)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("[click rollup] deleted %d raw clicks before %s", result.RowsAffected, deleteBefore.Format(time.RFC3339))
+		}
+
+		return nil
+	})
+}
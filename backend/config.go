@@ -1,6 +1,10 @@
 package main
 
-import "os"
+import (
+	"log"
+	"os"
+	"strconv"
+)
 
 func getEnv(key string, fallback string) string {
 	if v := os.Getenv(key); v != "" {
@@ -8,3 +12,16 @@ func getEnv(key string, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("%s must be number, using %d", key, fallback)
+		return fallback
+	}
+	return parsed
+}
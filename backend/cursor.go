@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type pageCursor struct {
+	At time.Time `json:"at"`
+	ID string    `json:"id"`
+}
+
+func encodePageCursor(at time.Time, id string) string {
+	payload, err := json.Marshal(pageCursor{At: at.UTC(), ID: id})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodePageCursor(raw string) (*pageCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	if cursor.ID == "" || cursor.At.IsZero() {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &cursor, nil
+}
+
+func parsePageLimit(raw string, fallback int, max int) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("limit must be positive number")
+	}
+	if limit > max {
+		limit = max
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	imageGCOrphanedFiles = "orphaned_files"
+	imageGCDanglingRows  = "dangling_rows"
+	imageGCUnusedImages  = "unused_images"
+
+	// アップロード途中のファイルや、作品への紐付け前の画像を消さないための猶予
+	imageGCGracePeriod = time.Hour
+)
+
+type imageGCDanglingRow struct {
+	ImageID    string `json:"image_id"`
+	Variant    string `json:"variant"`
+	FilePath   string `json:"file_path"`
+	Referenced bool   `json:"referenced"`
+}
+
+type imageGCReport struct {
+	OrphanedFiles []string             `json:"orphaned_files"`
+	DanglingRows  []imageGCDanglingRow `json:"dangling_rows"`
+	UnusedImages  []string             `json:"unused_images"`
+	Removed       []string             `json:"removed"`
+}
+
+type imageGCImageRow struct {
+	ID         string    `gorm:"column:id"`
+	FilePath   string    `gorm:"column:file_path"`
+	UploadedAt time.Time `gorm:"column:uploaded_at"`
+	Referenced bool      `gorm:"column:referenced"`
+}
+
+type imageGCVariantRow struct {
+	ImageID  string `gorm:"column:image_id"`
+	Variant  string `gorm:"column:variant"`
+	FilePath string `gorm:"column:file_path"`
+}
+
+func parseImageGCTargets(raw string) (map[string]bool, error) {
+	targets := map[string]bool{}
+	for _, target := range strings.Split(raw, ",") {
+		trimmed := strings.TrimSpace(target)
+		if trimmed == "" {
+			continue
+		}
+		switch trimmed {
+		case imageGCOrphanedFiles, imageGCDanglingRows, imageGCUnusedImages:
+			targets[trimmed] = true
+		default:
+			return nil, invalidParameter("remove", "remove must be one of orphaned_files, dangling_rows, unused_images")
+		}
+	}
+	return targets, nil
+}
+
+// GETは報告のみ。POSTではremoveに指定した種類を削除する
+func (pSrv *server) handleImageGC(c echo.Context) error {
+	targets := map[string]bool{}
+	if c.Request().Method == http.MethodPost {
+		parsed, err := parseImageGCTargets(c.QueryParam("remove"))
+		if err != nil {
+			return err
+		}
+		targets = parsed
+	}
+
+	ctx := c.Request().Context()
+	report, imageKeys, err := pSrv.collectImageGarbage(ctx, time.Now().Add(-imageGCGracePeriod))
+	if err != nil {
+		return internalError("failed to collect image garbage", err)
+	}
+
+	removed := make([]string, 0, len(targets))
+	if targets[imageGCUnusedImages] {
+		if err := pSrv.removeUnusedImages(ctx, report.UnusedImages, imageKeys); err != nil {
+			return internalError("failed to remove unused images", err)
+		}
+		removed = append(removed, imageGCUnusedImages)
+	}
+	if targets[imageGCDanglingRows] {
+		if err := pSrv.removeDanglingImageRows(ctx, report.DanglingRows, imageKeys); err != nil {
+			return internalError("failed to remove dangling rows", err)
+		}
+		removed = append(removed, imageGCDanglingRows)
+	}
+	if targets[imageGCOrphanedFiles] {
+		pSrv.removeImageFiles(ctx, report.OrphanedFiles)
+		removed = append(removed, imageGCOrphanedFiles)
+	}
+	report.Removed = removed
+
+	return c.JSON(http.StatusOK, report)
+}
+
+func (pSrv *server) collectImageGarbage(ctx context.Context, graceBefore time.Time) (*imageGCReport, map[string][]string, error) {
+	var images []imageGCImageRow
+	if err := pSrv.db.WithContext(ctx).Raw(
+		`
+		SELECT
+			i.id,
+			i.file_path,
+			i.uploaded_at,
+			EXISTS (SELECT 1 FROM isirmt_works w WHERE w.thumbnail_image_id = i.id)
+				OR EXISTS (SELECT 1 FROM isirmt_work_images wi WHERE wi.image_id = i.id) AS referenced
+		FROM common_images i
+		ORDER BY i.uploaded_at
+		`,
+	).Scan(&images).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var variants []imageGCVariantRow
+	if err := pSrv.db.WithContext(ctx).Raw(
+		`SELECT image_id, variant, file_path FROM common_image_variants`,
+	).Scan(&variants).Error; err != nil {
+		return nil, nil, err
+	}
+
+	report := &imageGCReport{
+		OrphanedFiles: []string{},
+		DanglingRows:  []imageGCDanglingRow{},
+		UnusedImages:  []string{},
+		Removed:       []string{},
+	}
+
+	imageVariants := make(map[string][]imageVariant, len(images))
+	for _, variant := range variants {
+		imageVariants[variant.ImageID] = append(imageVariants[variant.ImageID], imageVariant{
+			name:     variant.Variant,
+			filePath: variant.FilePath,
+		})
+	}
+
+	// 削除時にまとめて消すため、画像ごとのファイルのキーを返す
+	imageKeys := make(map[string][]string, len(images))
+	knownKeys := map[string]struct{}{}
+	for _, image := range images {
+		keys := imageFileKeys(image.FilePath, imageVariants[image.ID])
+		imageKeys[image.ID] = keys
+		for _, key := range keys {
+			knownKeys[key] = struct{}{}
+		}
+	}
+
+	// 1件ずつstatすると、S3では1ファイルごとにリクエストが飛ぶため、一覧から存在を確認する
+	existingKeys := map[string]struct{}{}
+	if err := pSrv.storage.walk(ctx, func(key string, _ int64, modTime time.Time) error {
+		existingKeys[key] = struct{}{}
+		if _, ok := knownKeys[key]; ok || modTime.After(graceBefore) {
+			return nil
+		}
+		report.OrphanedFiles = append(report.OrphanedFiles, key)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	for _, image := range images {
+		if !image.Referenced && image.UploadedAt.Before(graceBefore) {
+			report.UnusedImages = append(report.UnusedImages, image.ID)
+		}
+
+		if _, ok := existingKeys[image.FilePath]; !ok {
+			report.DanglingRows = append(report.DanglingRows, imageGCDanglingRow{
+				ImageID:    image.ID,
+				Variant:    imageVariantOriginal,
+				FilePath:   image.FilePath,
+				Referenced: image.Referenced,
+			})
+		}
+	}
+	for _, variant := range variants {
+		if _, ok := existingKeys[variant.FilePath]; !ok {
+			report.DanglingRows = append(report.DanglingRows, imageGCDanglingRow{
+				ImageID:  variant.ImageID,
+				Variant:  variant.Variant,
+				FilePath: variant.FilePath,
+			})
+		}
+	}
+
+	return report, imageKeys, nil
+}
+
+func (pSrv *server) removeUnusedImages(ctx context.Context, imageIDs []string, imageKeys map[string][]string) error {
+	for _, imageID := range imageIDs {
+		// 集計後に作品へ紐付けられた画像は残す
+		result := pSrv.db.WithContext(ctx).Exec(
+			`
+			DELETE FROM common_images i
+			WHERE i.id = ?
+				AND NOT EXISTS (SELECT 1 FROM isirmt_works w WHERE w.thumbnail_image_id = i.id)
+				AND NOT EXISTS (SELECT 1 FROM isirmt_work_images wi WHERE wi.image_id = i.id)
+			`,
+			imageID,
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			pSrv.removeImageFiles(ctx, imageKeys[imageID])
+		}
+	}
+	return nil
+}
+
+// 作品から参照されている画像の行は、サムネイルや画像一覧が外れてしまうため残す
+func (pSrv *server) removeDanglingImageRows(ctx context.Context, rows []imageGCDanglingRow, imageKeys map[string][]string) error {
+	for _, row := range rows {
+		var err error
+		if row.Variant == imageVariantOriginal {
+			if row.Referenced {
+				continue
+			}
+			err = pSrv.db.WithContext(ctx).Exec(`DELETE FROM common_images WHERE id = ?`, row.ImageID).Error
+			if err == nil {
+				pSrv.removeImageFiles(ctx, imageKeys[row.ImageID])
+			}
+		} else {
+			err = pSrv.db.WithContext(ctx).Exec(
+				`DELETE FROM common_image_variants WHERE image_id = ? AND variant = ?`,
+				row.ImageID,
+				row.Variant,
+			).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
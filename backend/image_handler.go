@@ -1,43 +1,49 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"realtime/internal/query"
 	"realtime/internal/query/model"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
-func (pSrv *server) handleGetImages(c echo.Context) error {
-	images, err := pSrv.q.CommonImage.WithContext(c.Request().Context()).Find()
-	if err != nil {
-		return c.String(500, "internal server error")
-	}
-	return c.JSON(200, images)
-}
+const (
+	imagePatchMaxBodyLength = 64 << 10 // 64 KiB
+	imageTextMaxLength      = 1000
+)
 
 func (pSrv *server) handleGetImage(c echo.Context) error {
 	imageID := c.Param("id")
 	if imageID == "" {
-		return c.String(400, "image id is required")
+		return invalidParameter("id", "image id is required")
 	}
 
 	ctx := c.Request().Context()
 	image, err := pSrv.fetchImageByID(ctx, imageID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.String(404, "image not found")
+			return notFound("image_not_found", "image not found")
 		}
-		return c.String(500, "failed to fetch image")
+		return internalError("failed to fetch image", err)
 	}
 
 	return c.JSON(200, image)
@@ -46,132 +52,702 @@ func (pSrv *server) handleGetImage(c echo.Context) error {
 func (pSrv *server) handleUploadImage(c echo.Context) error {
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
-		return c.String(400, "file is required")
+		return newAPIError(http.StatusBadRequest, "image_file_required", "file is required")
+	}
+
+	newImage, deduplicated, err := pSrv.storeUploadedImage(c.Request().Context(), fileHeader)
+	if err != nil {
+		return err
+	}
+
+	setAuditTargetID(c, *newImage.ID)
+	return c.JSON(200, uploadedImageResponse{
+		CommonImage:  newImage,
+		Deduplicated: deduplicated,
+	})
+}
+
+// JSON Merge Patch (RFC 7396) として送られた項目のみ更新する
+func (pSrv *server) handlePatchImage(c echo.Context) error {
+	imageID := strings.TrimSpace(c.Param("id"))
+	if imageID == "" {
+		return invalidParameter("id", "image id is required")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, imagePatchMaxBodyLength+1))
+	if err != nil {
+		return invalidRequestBody()
+	}
+	if len(body) > imagePatchMaxBodyLength {
+		return newAPIError(http.StatusRequestEntityTooLarge, "request_too_large", "request body is too large")
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return newAPIError(http.StatusBadRequest, "invalid_request_body", "request body must be JSON object")
+	}
+
+	ctx := c.Request().Context()
+	before, err := pSrv.fetchImageByID(ctx, imageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("image_not_found", "image not found")
+		}
+		return internalError("failed to fetch image", err)
 	}
+	setAuditBefore(c, before)
+
+	v := &workValidator{}
+	updates := map[string]interface{}{}
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch key {
+		case "alt_text", "caption", "credit":
+			value, ok := v.patchNullableString(patch[key], key)
+			if !ok {
+				continue
+			}
+			var text *string
+			if value != nil {
+				text = normalizeOptionalText(*value)
+			}
+			if text != nil && utf8.RuneCountInString(*text) > imageTextMaxLength {
+				v.add(key, validationCodeInvalidValue)
+				continue
+			}
+			updates[key] = text
+		default:
+			v.add(key, validationCodeUnknownField)
+		}
+	}
+	if !v.valid() {
+		return respondValidationErrors(c, v)
+	}
+	if len(updates) == 0 {
+		return c.JSON(http.StatusOK, before)
+	}
+
+	if _, err := pSrv.q.CommonImage.WithContext(ctx).Where(pSrv.q.CommonImage.ID.Eq(imageID)).Updates(updates); err != nil {
+		return internalError("failed to update image", err)
+	}
+
+	after, err := pSrv.fetchImageByID(ctx, imageID)
+	if err != nil {
+		return internalError("failed to fetch image", err)
+	}
+	setAuditAfter(c, after)
+	return c.JSON(http.StatusOK, after)
+}
+
+type uploadedImageResponse struct {
+	*model.CommonImage
+	Deduplicated bool `json:"deduplicated"`
+}
+
+func (pSrv *server) fetchImageByContentHash(ctx context.Context, contentHash string) (*model.CommonImage, error) {
+	return pSrv.q.CommonImage.WithContext(ctx).Where(pSrv.q.CommonImage.ContentHash.Eq(contentHash)).First()
+}
+
+// 形式を検証して保存し、寸法・代表色と縮小画像を記録する。同じ内容の画像があればそれを返す
+func (pSrv *server) storeUploadedImage(ctx context.Context, fileHeader *multipart.FileHeader) (*model.CommonImage, bool, error) {
 	if fileHeader.Size > 0 && fileHeader.Size > int64(pSrv.maxUploadSize) {
-		return c.String(http.StatusRequestEntityTooLarge, "file too large")
+		return nil, false, newAPIError(http.StatusRequestEntityTooLarge, "image_too_large", "file too large")
 	}
 
 	src, err := fileHeader.Open()
 	if err != nil {
-		return c.String(500, "failed to open file")
+		return nil, false, internalError("failed to open file", err)
 	}
 	defer src.Close()
 
+	return pSrv.storeImageFile(ctx, fileHeader.Filename, src)
+}
+
+// 検証・縮小・ストレージへの保存を行い、common_imagesに登録する
+// 同じ内容の画像が既にあれば、それを返してtrueを返す
+func (pSrv *server) storeImageFile(ctx context.Context, fileName string, src io.ReadSeeker) (*model.CommonImage, bool, error) {
+	mimeType, err := sniffImageType(src)
+	if err != nil {
+		if errors.Is(err, errUnsupportedImage) {
+			return nil, false, newAPIError(http.StatusUnsupportedMediaType, "unsupported_image_type", "file must be a JPEG, PNG, GIF or WebP image")
+		}
+		return nil, false, internalError("failed to read file", err)
+	}
+
 	imageId, err := uuid.NewRandom()
 	if err != nil {
-		return c.String(500, "failed to generate image id")
+		return nil, false, internalError("failed to generate image id", err)
 	}
 
 	idStr := imageId.String()
 
-	storageName := idStr + filepath.Ext(fileHeader.Filename)
-	dstPath := filepath.Join(pSrv.uploadDir, storageName)
+	// 解析と縮小はローカルの作業ディレクトリで行い、終わってからストレージへ送る
+	workDir, err := os.MkdirTemp("", "image-upload-")
+	if err != nil {
+		return nil, false, internalError("failed to create work dir", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	storageName := idStr + imageExtensions[mimeType]
+	dstPath := filepath.Join(workDir, storageName)
 	dst, err := os.Create(dstPath)
 	if err != nil {
-		return c.String(500, "failed to create file")
+		return nil, false, internalError("failed to create file", err)
 	}
 	defer dst.Close()
 
+	// 保存するのはメタデータを除いた後の内容なので、ハッシュもそちらで取る
+	hash := sha256.New()
 	limit := int64(pSrv.maxUploadSize) + 1
-	size, err := io.Copy(dst, io.LimitReader(src, limit))
+	read := &countingReader{r: io.LimitReader(src, limit)}
+	size, err := copyWithoutImageMetadata(io.MultiWriter(dst, hash), read, mimeType)
+	if read.n > int64(pSrv.maxUploadSize) {
+		return nil, false, newAPIError(http.StatusRequestEntityTooLarge, "image_too_large", "file too large")
+	}
 	if err != nil {
-		return c.String(500, "failed to save file")
+		if errors.Is(err, errInvalidJPEG) || errors.Is(err, errInvalidPNG) {
+			return nil, false, newAPIError(http.StatusUnsupportedMediaType, "invalid_image", "failed to decode image").withCause(err)
+		}
+		return nil, false, internalError("failed to save file", err)
 	}
 
-	if size > int64(pSrv.maxUploadSize) {
-		_ = dst.Close()
-		_ = os.Remove(dstPath)
-		return c.String(http.StatusRequestEntityTooLarge, "file too large")
+	contentHash := hex.EncodeToString(hash.Sum(nil))
+	if existing, err := pSrv.fetchImageByContentHash(ctx, contentHash); err == nil {
+		return existing, true, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, internalError("failed to fetch image", err)
 	}
 
 	if _, err := dst.Seek(0, io.SeekStart); err != nil {
-		return c.String(500, "failed to read saved file")
+		return nil, false, internalError("failed to read saved file", err)
+	}
+
+	analysis, err := analyzeImage(dst, mimeType)
+	if err != nil {
+		if errors.Is(err, errImageDimensions) {
+			return nil, false, newAPIError(http.StatusRequestEntityTooLarge, "image_too_large", "image dimensions too large")
+		}
+		return nil, false, newAPIError(http.StatusUnsupportedMediaType, "invalid_image", "failed to decode image").withCause(err)
+	}
+
+	variants, err := writeImageVariants(workDir, idStr, mimeType, analysis)
+	if err != nil {
+		return nil, false, internalError("failed to generate image variants", err)
 	}
 
-	_ = dst.Sync()
+	storedKeys := make([]string, 0, len(variants)+1)
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		pSrv.removeImageFiles(context.WithoutCancel(ctx), storedKeys)
+	}()
+
+	if err := putImageFile(ctx, pSrv.storage, storageName, dstPath, mimeType); err != nil {
+		return nil, false, internalError("failed to store file", err)
+	}
+	storedKeys = append(storedKeys, storageName)
+	for _, variant := range variants {
+		if err := putImageFile(ctx, pSrv.storage, variant.filePath, filepath.Join(workDir, variant.filePath), variant.mimeType); err != nil {
+			return nil, false, internalError("failed to store image variants", err)
+		}
+		storedKeys = append(storedKeys, variant.filePath)
+	}
 
+	width := int32(analysis.width)
+	height := int32(analysis.height)
 	newImage := &model.CommonImage{
-		ID:       &idStr,
-		FileName: fileHeader.Filename,
-		FilePath: storageName,
-		MimeType: fileHeader.Header.Get("Content-Type"),
-		FileSize: size,
+		ID:            &idStr,
+		FileName:      fileName,
+		FilePath:      storageName,
+		MimeType:      mimeType,
+		FileSize:      size,
+		Width:         &width,
+		Height:        &height,
+		DominantColor: analysis.dominantColor,
+		ContentHash:   &contentHash,
 	}
-	if err := pSrv.q.CommonImage.WithContext(c.Request().Context()).Create(newImage); err != nil {
-		return c.String(500, "failed to save image info")
+	if err := pSrv.q.Transaction(func(tx *query.Query) error {
+		if err := tx.CommonImage.WithContext(ctx).Create(newImage); err != nil {
+			return err
+		}
+		return insertImageVariants(ctx, tx, idStr, variants)
+	}); err != nil {
+		// 同じ内容が同時にアップロードされた場合は、先に保存された方を返す
+		if existing, fetchErr := pSrv.fetchImageByContentHash(ctx, contentHash); fetchErr == nil {
+			return existing, true, nil
+		}
+		return nil, false, internalError("failed to save image info", err)
 	}
 
-	return c.JSON(200, newImage)
+	stored = true
+	return newImage, false, nil
+}
+
+func insertImageVariants(ctx context.Context, tx *query.Query, imageID string, variants []imageVariant) error {
+	db := tx.CommonImage.WithContext(ctx).UnderlyingDB()
+	for _, variant := range variants {
+		if err := db.Exec(
+			`
+			INSERT INTO common_image_variants (image_id, variant, width, height, file_path, mime_type, file_size)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			`,
+			imageID,
+			variant.name,
+			variant.width,
+			variant.height,
+			variant.filePath,
+			variant.mimeType,
+			variant.fileSize,
+		).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pSrv *server) fetchImageVariants(ctx context.Context, imageID string) ([]imageVariant, error) {
+	type row struct {
+		Variant  string `gorm:"column:variant"`
+		Width    int    `gorm:"column:width"`
+		Height   int    `gorm:"column:height"`
+		FilePath string `gorm:"column:file_path"`
+		MimeType string `gorm:"column:mime_type"`
+		FileSize int64  `gorm:"column:file_size"`
+	}
+
+	var rows []row
+	err := pSrv.db.WithContext(ctx).Raw(
+		`
+		SELECT variant, width, height, file_path, mime_type, file_size
+		FROM common_image_variants
+		WHERE image_id = ?
+		ORDER BY width
+		`,
+		imageID,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]imageVariant, 0, len(rows))
+	for _, row := range rows {
+		variants = append(variants, imageVariant{
+			name:     row.Variant,
+			width:    row.Width,
+			height:   row.Height,
+			filePath: row.FilePath,
+			mimeType: row.MimeType,
+			fileSize: row.FileSize,
+		})
+	}
+	return variants, nil
+}
+
+// 0なら元画像を配信する
+func parseRequestedImageWidth(c echo.Context) (int, error) {
+	if name := strings.TrimSpace(c.QueryParam("variant")); name != "" {
+		if !isImageVariant(name) {
+			return 0, invalidParameter("variant", "variant must be one of original, large, medium, thumbnail")
+		}
+		return imageVariantWidth(name), nil
+	}
+
+	if raw := strings.TrimSpace(c.QueryParam("w")); raw != "" {
+		width, err := strconv.Atoi(raw)
+		if err != nil || width <= 0 {
+			return 0, invalidParameter("w", "w must be positive number")
+		}
+		return width, nil
+	}
+
+	return 0, nil
+}
+
+// 要求幅以上で最も小さいものを選び、なければ元画像を返す
+func selectImageVariant(image *model.CommonImage, variants []imageVariant, width int) imageVariant {
+	original := imageVariant{
+		name:     imageVariantOriginal,
+		filePath: image.FilePath,
+		mimeType: image.MimeType,
+		fileSize: image.FileSize,
+	}
+	if image.Width != nil {
+		original.width = int(*image.Width)
+	}
+	if width <= 0 {
+		return original
+	}
+
+	for _, variant := range variants {
+		if variant.width >= width {
+			return variant
+		}
+	}
+	return original
 }
 
 func (pSrv *server) handleServeImage(c echo.Context) error {
 	imageID := c.Param("id")
 	if imageID == "" {
-		return c.String(400, "image id is required")
+		return invalidParameter("id", "image id is required")
+	}
+	width, err := parseRequestedImageWidth(c)
+	if err != nil {
+		return err
 	}
 
 	ctx := c.Request().Context()
 	image, err := pSrv.fetchImageByID(ctx, imageID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.String(404, "image not found")
+			return notFound("image_not_found", "image not found")
+		}
+		return internalError("failed to fetch image", err)
+	}
+
+	var variants []imageVariant
+	if width > 0 {
+		variants, err = pSrv.fetchImageVariants(ctx, imageID)
+		if err != nil {
+			return internalError("failed to fetch image variants", err)
 		}
-		return c.String(500, "failed to fetch image")
 	}
 
-	return pSrv.serveImageContent(c, image)
+	return pSrv.serveImageContent(c, image, selectImageVariant(image, variants, width))
 }
 
 func (pSrv *server) fetchImageByID(ctx context.Context, imageID string) (*model.CommonImage, error) {
 	return pSrv.q.CommonImage.WithContext(ctx).Where(pSrv.q.CommonImage.ID.Eq(imageID)).First()
 }
 
-func (pSrv *server) serveImageContent(c echo.Context, image *model.CommonImage) error {
-	filePath := filepath.Join(pSrv.uploadDir, image.FilePath)
-	file, err := os.Open(filePath)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return c.String(404, "image file missing")
+// 元画像そのものは内容のSHA-256、縮小・変換したものはそれに種類を付けて区別する
+func imageETag(image *model.CommonImage, variant imageVariant) string {
+	tag := *image.ID
+	if image.ContentHash != nil {
+		tag = *image.ContentHash
+	}
+	if variant.name == imageVariantOriginal && variant.mimeType == image.MimeType {
+		return `"` + tag + `"`
+	}
+	return `"` + tag + "-" + variant.name + "-" + strings.TrimPrefix(variant.mimeType, "image/") + `"`
+}
+
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
 		}
-		return c.String(500, "failed to open image")
 	}
-	defer file.Close()
+	return false
+}
+
+func (pSrv *server) serveImageContent(c echo.Context, image *model.CommonImage, variant imageVariant) error {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	ctx := c.Request().Context()
+	if derived, ok := pSrv.imageTranscoder.negotiate(ctx, c.Request().Header.Get(echo.HeaderAccept), variant); ok {
+		variant = derived
+	}
 
-	fileInfo, err := file.Stat()
+	etag := imageETag(image, variant)
+	c.Response().Header().Set("ETag", etag)
+	if etagMatches(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	if pSrv.imageURLTTL > 0 {
+		signedURL, err := pSrv.storage.signedURL(ctx, variant.filePath, pSrv.imageURLTTL)
+		if err != nil {
+			return internalError("failed to sign image url", err)
+		}
+		if signedURL != "" {
+			// 署名の期限より先にキャッシュが切れるようにする
+			c.Response().Header().Set(echo.HeaderCacheControl, "private, max-age="+strconv.Itoa(int(pSrv.imageURLTTL.Seconds()/2)))
+			return c.Redirect(http.StatusFound, signedURL)
+		}
+	}
+
+	object, err := pSrv.storage.open(ctx, variant.filePath)
 	if err != nil {
-		return c.String(500, "failed to read image info")
+		if errors.Is(err, errImageObjectNotFound) {
+			return notFound("image_file_missing", "image file missing")
+		}
+		return internalError("failed to open image", err)
 	}
+	defer object.body.Close()
 
-	c.Response().Header().Set(echo.HeaderContentType, image.MimeType)
-	c.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(image.FileSize, 10))
+	c.Response().Header().Set(echo.HeaderContentType, variant.mimeType)
 	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=31536000")
 
-	http.ServeContent(c.Response(), c.Request(), image.FileName, fileInfo.ModTime(), file)
-	return nil
+	// ローカルのファイルはRangeや条件付きリクエストをServeContentに任せる
+	if content, ok := object.body.(io.ReadSeeker); ok {
+		c.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(object.size, 10))
+		http.ServeContent(c.Response(), c.Request(), image.FileName, time.Time{}, content)
+		return nil
+	}
+
+	if object.size >= 0 {
+		c.Response().Header().Set(echo.HeaderContentLength, strconv.FormatInt(object.size, 10))
+	}
+	return c.Stream(http.StatusOK, variant.mimeType, object.body)
+}
+
+var errImageInUse = errors.New("image is referenced by works")
+
+type imageReference struct {
+	WorkID        string `gorm:"column:work_id" json:"work_id"`
+	Title         string `gorm:"column:title" json:"title"`
+	Status        string `gorm:"column:status" json:"status"`
+	Trashed       bool   `gorm:"column:trashed" json:"trashed"`
+	Thumbnail     bool   `gorm:"column:thumbnail" json:"thumbnail"`
+	GalleryImages int    `gorm:"column:gallery_images" json:"gallery_images"`
 }
 
+type imageInUseDetails struct {
+	Works []imageReference `json:"works"`
+}
+
+type deletedImageResponse struct {
+	ID       string           `json:"id"`
+	Detached []imageReference `json:"detached"`
+}
+
+// ゴミ箱の作品も復元できるため含める
+func fetchImageReferences(db *gorm.DB, imageID string) ([]imageReference, error) {
+	references := []imageReference{}
+	err := db.Raw(
+		`
+		SELECT
+			w.id AS work_id,
+			w.title,
+			w.status,
+			w.deleted_at IS NOT NULL AS trashed,
+			COALESCE(w.thumbnail_image_id = ?, FALSE) AS thumbnail,
+			(SELECT COUNT(*) FROM isirmt_work_images wi WHERE wi.work_id = w.id AND wi.image_id = ?) AS gallery_images
+		FROM isirmt_works w
+		WHERE w.thumbnail_image_id = ?
+			OR EXISTS (SELECT 1 FROM isirmt_work_images wi WHERE wi.work_id = w.id AND wi.image_id = ?)
+		ORDER BY w.created_at DESC, w.id DESC
+		FOR UPDATE OF w
+		`,
+		imageID,
+		imageID,
+		imageID,
+		imageID,
+	).Scan(&references).Error
+	return references, err
+}
+
+// 作品から参照されている画像は409を返す。force=trueなら作品から外してから削除する
 func (pSrv *server) handleDeleteImage(c echo.Context) error {
 	imageID := strings.TrimSpace(c.Param("id"))
 	if imageID == "" {
-		return c.String(400, "image id is required")
+		return invalidParameter("id", "image id is required")
+	}
+
+	force := false
+	switch strings.TrimSpace(c.QueryParam("force")) {
+	case "", "false":
+	case "true":
+		force = true
+	default:
+		return invalidParameter("force", "force must be true or false")
 	}
 
 	ctx := c.Request().Context()
-	if _, err := pSrv.q.CommonImage.WithContext(ctx).Where(pSrv.q.CommonImage.ID.Eq(imageID)).First(); err != nil {
+	image, err := pSrv.q.CommonImage.WithContext(ctx).Where(pSrv.q.CommonImage.ID.Eq(imageID)).First()
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.String(404, "image not found")
+			return notFound("image_not_found", "image not found")
 		}
-		return c.String(500, "failed to fetch image")
+		return internalError("failed to fetch image", err)
+	}
+	variants, err := pSrv.fetchImageVariants(ctx, imageID)
+	if err != nil {
+		return internalError("failed to fetch image variants", err)
 	}
 
+	var references []imageReference
 	if err := pSrv.q.Transaction(func(tx *query.Query) error {
-		if _, err := tx.CommonImage.WithContext(ctx).Where(tx.CommonImage.ID.Eq(imageID)).Delete(); err != nil {
+		db := tx.CommonImage.WithContext(ctx).UnderlyingDB()
+
+		// 確認から削除までの間に作品から参照されないよう、画像の行をロックする
+		var locked []string
+		if err := db.Raw(`SELECT id FROM common_images WHERE id = ? FOR UPDATE`, imageID).Scan(&locked).Error; err != nil {
 			return err
 		}
-		return nil
+		if len(locked) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var err error
+		references, err = fetchImageReferences(db, imageID)
+		if err != nil {
+			return err
+		}
+		if len(references) > 0 && !force {
+			return errImageInUse
+		}
+
+		for _, reference := range references {
+			// ゴミ箱の作品は一覧から取得できないため、履歴は残さない
+			if !reference.Trashed {
+				if err := pSrv.recordWorkRevisionBaseline(ctx, tx, reference.WorkID); err != nil {
+					return err
+				}
+			}
+			if err := db.Exec(
+				`DELETE FROM isirmt_work_images WHERE work_id = ? AND image_id = ?`,
+				reference.WorkID,
+				imageID,
+			).Error; err != nil {
+				return err
+			}
+			if err := db.Exec(
+				`
+				UPDATE isirmt_works
+				SET thumbnail_image_id = CASE WHEN thumbnail_image_id = ? THEN NULL ELSE thumbnail_image_id END,
+					version = version + 1,
+					updated_at = NOW()
+				WHERE id = ?
+				`,
+				imageID,
+				reference.WorkID,
+			).Error; err != nil {
+				return err
+			}
+			if !reference.Trashed {
+				if err := pSrv.recordWorkRevision(ctx, tx, reference.WorkID, adminEmail(c)); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = tx.CommonImage.WithContext(ctx).Where(tx.CommonImage.ID.Eq(imageID)).Delete()
+		return err
 	}); err != nil {
-		return c.String(500, "failed to delete image")
+		if errors.Is(err, errImageInUse) {
+			return newAPIError(http.StatusConflict, "image_in_use", "image is referenced by works").
+				withDetails(imageInUseDetails{Works: references})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("image_not_found", "image not found")
+		}
+		return internalError("failed to delete image", err)
+	}
+
+	pSrv.removeImageFiles(context.WithoutCancel(ctx), imageFileKeys(image.FilePath, variants))
+	return c.JSON(http.StatusOK, deletedImageResponse{
+		ID:       imageID,
+		Detached: references,
+	})
+}
+
+func imageFileKeys(filePath string, variants []imageVariant) []string {
+	sources := []string{filePath}
+	for _, variant := range variants {
+		sources = append(sources, variant.filePath)
+	}
+
+	keys := make([]string, 0, len(sources)*(len(imageTranscodeFormats)+1))
+	for _, source := range sources {
+		keys = append(keys, source)
+		for _, format := range imageTranscodeFormats {
+			keys = append(keys, derivedImageKey(source, format.name))
+		}
+	}
+	return keys
+}
+
+// 行の削除後に呼ぶ。消し残したファイルはGCで回収できるため、失敗はログに留める
+func (pSrv *server) removeImageFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := pSrv.storage.remove(ctx, key); err != nil {
+			log.Printf("[image] failed to remove %s: %v", key, err)
+		}
+	}
+}
+
+// 重複検出を入れる前にアップロードされた画像にもハッシュを付ける
+// できる範囲で付けるだけで、付けられなかった行は次の起動時にまた試す
+// ファイルが無い行はGCのdangling_rowsで見つかるため黙って飛ばし、既存の画像と同じ内容の行は件数だけログに出す
+func (pSrv *server) backfillImageContentHashes(ctx context.Context) {
+	images, err := pSrv.q.CommonImage.WithContext(ctx).
+		Where(pSrv.q.CommonImage.ContentHash.IsNull(), pSrv.q.CommonImage.DuplicateOfImageID.IsNull()).
+		Find()
+	if err != nil {
+		log.Printf("[image hash] failed to fetch images: %v", err)
+		return
+	}
+
+	var duplicates int
+	for _, image := range images {
+		contentHash, err := pSrv.hashStoredImage(ctx, image)
+		if err != nil {
+			if !errors.Is(err, errImageObjectNotFound) {
+				log.Printf("[image hash] failed to read %s: %v", image.FilePath, err)
+			}
+			continue
+		}
+
+		// 既存の画像と同じ内容なら一意制約に反するため、ハッシュの代わりに元の画像を記録して次回以降は読まない
+		if canonical, err := pSrv.fetchImageByContentHash(ctx, contentHash); err == nil {
+			pSrv.markDuplicateImage(ctx, image, canonical)
+			duplicates++
+			continue
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[image hash] failed to check %s: %v", *image.ID, err)
+			continue
+		}
+
+		if _, err := pSrv.q.CommonImage.WithContext(ctx).
+			Where(pSrv.q.CommonImage.ID.Eq(*image.ID)).
+			Update(pSrv.q.CommonImage.ContentHash, contentHash); err != nil {
+			// 確認の後に同じ内容がアップロードされた場合
+			if canonical, fetchErr := pSrv.fetchImageByContentHash(ctx, contentHash); fetchErr == nil {
+				pSrv.markDuplicateImage(ctx, image, canonical)
+				duplicates++
+				continue
+			}
+			log.Printf("[image hash] failed to update %s: %v", *image.ID, err)
+		}
+	}
+	if duplicates > 0 {
+		log.Printf("[image hash] %d images duplicate other images and were left without hash", duplicates)
+	}
+}
+
+func (pSrv *server) markDuplicateImage(ctx context.Context, image, canonical *model.CommonImage) {
+	if _, err := pSrv.q.CommonImage.WithContext(ctx).
+		Where(pSrv.q.CommonImage.ID.Eq(*image.ID)).
+		Update(pSrv.q.CommonImage.DuplicateOfImageID, *canonical.ID); err != nil {
+		log.Printf("[image hash] failed to mark %s as duplicate: %v", *image.ID, err)
+	}
+}
+
+// 新しいアップロードと突き合わせられるよう、メタデータを除いた内容でハッシュを取る
+// 除けない壊れたファイルはそのままの内容で取る
+func (pSrv *server) hashStoredImage(ctx context.Context, image *model.CommonImage) (string, error) {
+	object, err := pSrv.storage.open(ctx, image.FilePath)
+	if err != nil {
+		return "", err
+	}
+	content, err := io.ReadAll(object.body)
+	object.body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := copyWithoutImageMetadata(hash, bytes.NewReader(content), image.MimeType); err != nil {
+		hash.Reset()
+		hash.Write(content)
 	}
-	return c.String(200, "ok")
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
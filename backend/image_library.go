@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"realtime/internal/query"
+	"realtime/internal/query/model"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gen/field"
+)
+
+type imageListFilter struct {
+	mimeTypes []string
+	fileName  string
+	unused    bool
+}
+
+type imagePageResponse struct {
+	Items      []*model.CommonImage `json:"items"`
+	NextCursor *string              `json:"next_cursor"`
+}
+
+type imageUsageWork struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Status  string `json:"status"`
+	Trashed bool   `json:"trashed"`
+}
+
+type libraryImageResponse struct {
+	*model.CommonImage
+	UsageCount int              `json:"usage_count"`
+	UsedBy     []imageUsageWork `json:"used_by"`
+}
+
+type imageLibraryPageResponse struct {
+	Items      []libraryImageResponse `json:"items"`
+	NextCursor *string                `json:"next_cursor"`
+}
+
+type imageUsageRow struct {
+	ImageID string `gorm:"column:image_id"`
+	WorkID  string `gorm:"column:work_id"`
+	Title   string `gorm:"column:title"`
+	Status  string `gorm:"column:status"`
+	Trashed bool   `gorm:"column:trashed"`
+}
+
+func parseImageListFilter(c echo.Context) (*imageListFilter, error) {
+	filter := &imageListFilter{}
+
+	mimeSet := map[string]struct{}{}
+	for _, rawTypes := range c.QueryParams()["mime_type"] {
+		for _, mimeType := range strings.Split(rawTypes, ",") {
+			trimmed := strings.ToLower(strings.TrimSpace(mimeType))
+			if trimmed == "" {
+				continue
+			}
+			if _, ok := imageExtensions[trimmed]; !ok {
+				return nil, invalidParameter("mime_type", "mime_type must be one of image/jpeg, image/png, image/gif, image/webp")
+			}
+			if _, exists := mimeSet[trimmed]; exists {
+				continue
+			}
+			mimeSet[trimmed] = struct{}{}
+			filter.mimeTypes = append(filter.mimeTypes, trimmed)
+		}
+	}
+
+	filter.fileName = strings.TrimSpace(c.QueryParam("q"))
+
+	return filter, nil
+}
+
+// LIKEの特殊文字をそのままの文字として扱う
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func (pSrv *server) applyImageListFilter(ctx context.Context, imageQuery query.ICommonImageDo, filter *imageListFilter) query.ICommonImageDo {
+	if len(filter.mimeTypes) > 0 {
+		imageQuery = imageQuery.Where(pSrv.q.CommonImage.MimeType.In(filter.mimeTypes...))
+	}
+	if filter.fileName != "" {
+		pattern := "%" + escapeLikePattern(strings.ToLower(filter.fileName)) + "%"
+		imageQuery = imageQuery.Where(pSrv.q.CommonImage.FileName.Lower().Like(pattern))
+	}
+	if filter.unused {
+		// ゴミ箱の作品も復元できるため、参照しているものとして扱う
+		workImage := pSrv.q.IsirmtWorkImage
+		work := pSrv.q.IsirmtWork
+		imageQuery = imageQuery.Where(
+			pSrv.q.CommonImage.Columns(pSrv.q.CommonImage.ID).NotIn(
+				workImage.WithContext(ctx).Select(workImage.ImageID),
+			),
+			pSrv.q.CommonImage.Columns(pSrv.q.CommonImage.ID).NotIn(
+				work.WithContext(ctx).Unscoped().Select(work.ThumbnailImageID).Where(work.ThumbnailImageID.IsNotNull()),
+			),
+		)
+	}
+
+	return imageQuery
+}
+
+func (pSrv *server) listImages(c echo.Context, filter *imageListFilter) ([]*model.CommonImage, *string, error) {
+	limit, err := parsePageLimit(c.QueryParam("limit"), 50, 200)
+	if err != nil {
+		return nil, nil, invalidParameter("limit", err.Error())
+	}
+
+	ctx := c.Request().Context()
+	imageQuery := pSrv.applyImageListFilter(ctx, pSrv.q.CommonImage.WithContext(ctx), filter)
+
+	if rawCursor := strings.TrimSpace(c.QueryParam("cursor")); rawCursor != "" {
+		cursor, err := decodePageCursor(rawCursor)
+		if err != nil {
+			return nil, nil, invalidParameter("cursor", "cursor is invalid")
+		}
+		imageQuery = imageQuery.Where(field.Or(
+			pSrv.q.CommonImage.UploadedAt.Lt(cursor.At),
+			field.And(pSrv.q.CommonImage.UploadedAt.Eq(cursor.At), pSrv.q.CommonImage.ID.Lt(cursor.ID)),
+		))
+	}
+
+	images, err := imageQuery.
+		Order(pSrv.q.CommonImage.UploadedAt.Desc(), pSrv.q.CommonImage.ID.Desc()).
+		Limit(limit + 1).
+		Find()
+	if err != nil {
+		return nil, nil, internalError("failed to fetch images", err)
+	}
+
+	var nextCursor *string
+	if len(images) > limit {
+		images = images[:limit]
+		last := images[len(images)-1]
+		if last.ID != nil && last.UploadedAt != nil {
+			cursor := encodePageCursor(*last.UploadedAt, *last.ID)
+			nextCursor = &cursor
+		}
+	}
+
+	return images, nextCursor, nil
+}
+
+func (pSrv *server) handleGetImages(c echo.Context) error {
+	filter, err := parseImageListFilter(c)
+	if err != nil {
+		return err
+	}
+
+	images, nextCursor, err := pSrv.listImages(c, filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, imagePageResponse{
+		Items:      images,
+		NextCursor: nextCursor,
+	})
+}
+
+// 管理画面の画像選択用。下書きの作品名も含むため管理者のみ
+func (pSrv *server) handleGetImageLibrary(c echo.Context) error {
+	filter, err := parseImageListFilter(c)
+	if err != nil {
+		return err
+	}
+	if raw := strings.TrimSpace(c.QueryParam("unused")); raw != "" {
+		switch raw {
+		case "true":
+			filter.unused = true
+		case "false":
+		default:
+			return invalidParameter("unused", "unused must be true or false")
+		}
+	}
+
+	images, nextCursor, err := pSrv.listImages(c, filter)
+	if err != nil {
+		return err
+	}
+
+	imageIDs := make([]string, 0, len(images))
+	for _, image := range images {
+		if image.ID != nil {
+			imageIDs = append(imageIDs, *image.ID)
+		}
+	}
+	usages, err := pSrv.fetchImageUsages(c.Request().Context(), imageIDs)
+	if err != nil {
+		return internalError("failed to fetch image usages", err)
+	}
+
+	items := make([]libraryImageResponse, 0, len(images))
+	for _, image := range images {
+		usedBy := []imageUsageWork{}
+		if image.ID != nil && usages[*image.ID] != nil {
+			usedBy = usages[*image.ID]
+		}
+		items = append(items, libraryImageResponse{
+			CommonImage: image,
+			UsageCount:  len(usedBy),
+			UsedBy:      usedBy,
+		})
+	}
+
+	return c.JSON(http.StatusOK, imageLibraryPageResponse{
+		Items:      items,
+		NextCursor: nextCursor,
+	})
+}
+
+// 画像ごとに、サムネイルまたは作品画像として参照している作品を返す
+func (pSrv *server) fetchImageUsages(ctx context.Context, imageIDs []string) (map[string][]imageUsageWork, error) {
+	usages := map[string][]imageUsageWork{}
+	if len(imageIDs) == 0 {
+		return usages, nil
+	}
+
+	var rows []imageUsageRow
+	if err := pSrv.db.WithContext(ctx).Raw(
+		`
+		SELECT u.image_id, w.id AS work_id, w.title, w.status, w.deleted_at IS NOT NULL AS trashed
+		FROM (
+			SELECT wi.image_id, wi.work_id FROM isirmt_work_images wi WHERE wi.image_id IN ?
+			UNION
+			SELECT w.thumbnail_image_id, w.id FROM isirmt_works w WHERE w.thumbnail_image_id IN ?
+		) u
+		JOIN isirmt_works w ON w.id = u.work_id
+		ORDER BY w.created_at DESC, w.id DESC
+		`,
+		imageIDs,
+		imageIDs,
+	).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		usages[row.ImageID] = append(usages[row.ImageID], imageUsageWork{
+			ID:      row.WorkID,
+			Title:   row.Title,
+			Status:  row.Status,
+			Trashed: row.Trashed,
+		})
+	}
+	return usages, nil
+}
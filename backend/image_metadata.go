@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	errInvalidJPEG = errors.New("invalid jpeg structure")
+	errInvalidPNG  = errors.New("invalid png structure")
+)
+
+var (
+	jpegExifPrefix = []byte("Exif\x00\x00")
+	pngSignature   = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// 撮影情報や位置情報を含むチャンク
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// EXIF・XMPなどのメタデータを取り除きながらコピーし、書き込んだバイト数を返す
+// JPEG・PNG以外はそのままコピーする
+func copyWithoutImageMetadata(dst io.Writer, src io.Reader, mimeType string) (int64, error) {
+	w := &countingWriter{w: dst}
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		err = stripJPEGMetadata(w, src)
+	case "image/png":
+		err = stripPNGMetadata(w, src)
+	default:
+		_, err = io.Copy(w, src)
+	}
+	return w.n, err
+}
+
+// APP0 (JFIF)・APP2 (ICCプロファイル)・APP14 (Adobe) 以外のAPPnとコメントを落とす
+// 向きが分からなくなると表示が回転してしまうため、EXIFのOrientationだけは残す
+func stripJPEGMetadata(dst io.Writer, src io.Reader) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(src, header); err != nil || header[0] != 0xff || header[1] != 0xd8 {
+		return errInvalidJPEG
+	}
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	marker := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(src, marker); err != nil {
+			return errInvalidJPEG
+		}
+		if marker[0] != 0xff {
+			return errInvalidJPEG
+		}
+		// 0xFFの連続は詰め物
+		for marker[0] == 0xff {
+			if _, err := io.ReadFull(src, marker); err != nil {
+				return errInvalidJPEG
+			}
+		}
+		code := marker[0]
+
+		switch {
+		case code == 0xda:
+			// SOS以降は画像データなので、残りはそのまま書き出す
+			if _, err := dst.Write([]byte{0xff, code}); err != nil {
+				return err
+			}
+			_, err := io.Copy(dst, src)
+			return err
+		case code == 0xd9:
+			_, err := dst.Write([]byte{0xff, code})
+			return err
+		case code == 0x01 || (code >= 0xd0 && code <= 0xd7):
+			if _, err := dst.Write([]byte{0xff, code}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		length := make([]byte, 2)
+		if _, err := io.ReadFull(src, length); err != nil {
+			return errInvalidJPEG
+		}
+		size := int(binary.BigEndian.Uint16(length))
+		if size < 2 {
+			return errInvalidJPEG
+		}
+		payload := make([]byte, size-2)
+		if _, err := io.ReadFull(src, payload); err != nil {
+			return errInvalidJPEG
+		}
+
+		isApp := code >= 0xe0 && code <= 0xef
+		if (isApp && code != 0xe0 && code != 0xe2 && code != 0xee) || code == 0xfe {
+			if code == 0xe1 && bytes.HasPrefix(payload, jpegExifPrefix) {
+				if orientation := exifOrientation(payload[len(jpegExifPrefix):]); orientation > 1 {
+					if _, err := dst.Write(orientationOnlyExif(orientation)); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		if _, err := dst.Write([]byte{0xff, code, length[0], length[1]}); err != nil {
+			return err
+		}
+		if _, err := dst.Write(payload); err != nil {
+			return err
+		}
+	}
+}
+
+// TIFF形式のEXIFからIFD0のOrientationを読む。見つからなければ0を返す
+func exifOrientation(tiff []byte) uint16 {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(tiff[2:4]) != 42 {
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// 0x0112: Orientation, 3: SHORT
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 && order.Uint16(tiff[entry+2:entry+4]) == 3 {
+			orientation := order.Uint16(tiff[entry+8 : entry+10])
+			if orientation > 8 {
+				return 0
+			}
+			return orientation
+		}
+	}
+	return 0
+}
+
+// Orientationだけを持つAPP1セグメント
+func orientationOnlyExif(orientation uint16) []byte {
+	segment := []byte{0xff, 0xe1, 0x00, 0x22}
+	segment = append(segment, jpegExifPrefix...)
+	segment = append(segment, 'M', 'M', 0x00, 0x2a, 0x00, 0x00, 0x00, 0x08)
+	segment = append(segment, 0x00, 0x01)
+	segment = append(segment, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	segment = binary.BigEndian.AppendUint16(segment, orientation)
+	segment = append(segment, 0x00, 0x00)
+	return append(segment, 0x00, 0x00, 0x00, 0x00)
+}
+
+// テキスト系のチャンク (XMPはiTXtに入る) とeXIfを落とす
+func stripPNGMetadata(dst io.Writer, src io.Reader) error {
+	signature := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(src, signature); err != nil || !bytes.Equal(signature, pngSignature) {
+		return errInvalidPNG
+	}
+	if _, err := dst.Write(signature); err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(src, header); err != nil {
+			return errInvalidPNG
+		}
+		// データ長 + CRC
+		size := int64(binary.BigEndian.Uint32(header[:4])) + 4
+		chunkType := string(header[4:8])
+
+		if pngMetadataChunks[chunkType] {
+			if _, err := io.CopyN(io.Discard, src, size); err != nil {
+				return errInvalidPNG
+			}
+			continue
+		}
+
+		if _, err := dst.Write(header); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dst, src, size); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errInvalidPNG
+			}
+			return err
+		}
+		if chunkType == "IEND" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 32), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func jpegSegment(code byte, payload []byte) []byte {
+	segment := []byte{0xff, code}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// Orientation (6) とGPS IFDへのポインタを持つEXIF
+func testExifPayload() []byte {
+	payload := append([]byte{}, jpegExifPrefix...)
+	payload = append(payload, 'I', 'I', 0x2a, 0x00, 0x08, 0x00, 0x00, 0x00)
+	payload = append(payload, 0x02, 0x00)
+	payload = append(payload, 0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00)
+	payload = append(payload, 0x25, 0x88, 0x04, 0x00, 0x01, 0x00, 0x00, 0x00, 0x26, 0x00, 0x00, 0x00)
+	payload = append(payload, 0x00, 0x00, 0x00, 0x00)
+	// GPS IFD: GPSLatitudeRef = "N"
+	payload = append(payload, 0x01, 0x00)
+	payload = append(payload, 0x01, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 'N', 0x00, 0x00, 0x00)
+	return append(payload, 0x00, 0x00, 0x00, 0x00)
+}
+
+func testJPEGWithMetadata(t *testing.T) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, testImage(), nil); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2])
+	out.Write(jpegSegment(0xe1, testExifPayload()))
+	out.Write(jpegSegment(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>secret</x:xmpmeta>")))
+	out.Write(jpegSegment(0xfe, []byte("secret comment")))
+	out.Write(encoded.Bytes()[2:])
+	return out.Bytes()
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func testPNGWithMetadata(t *testing.T) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage()); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+
+	// シグネチャ (8) + IHDR (25) の後ろに差し込む
+	headerEnd := len(pngSignature) + 25
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:headerEnd])
+	out.Write(pngChunk("tEXt", []byte("Comment\x00secret comment")))
+	out.Write(pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta>secret</x:xmpmeta>")))
+	out.Write(encoded.Bytes()[headerEnd:])
+	return out.Bytes()
+}
+
+// SOSより前のセグメントを順に返す
+func jpegHeaderSegments(t *testing.T, data []byte) map[byte][][]byte {
+	t.Helper()
+	segments := map[byte][][]byte{}
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xff {
+			t.Fatalf("unexpected byte %#x at %d", data[offset], offset)
+		}
+		code := data[offset+1]
+		if code == 0xda {
+			return segments
+		}
+		size := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		segments[code] = append(segments[code], data[offset+4:offset+2+size])
+		offset += 2 + size
+	}
+	t.Fatalf("SOS not found")
+	return nil
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	input := testJPEGWithMetadata(t)
+
+	var out bytes.Buffer
+	written, err := copyWithoutImageMetadata(&out, bytes.NewReader(input), "image/jpeg")
+	if err != nil {
+		t.Fatalf("failed to strip metadata: %v", err)
+	}
+	if written != int64(out.Len()) {
+		t.Errorf("written = %d, want %d", written, out.Len())
+	}
+
+	segments := jpegHeaderSegments(t, out.Bytes())
+	if len(segments[0xfe]) != 0 {
+		t.Errorf("comment segment remains")
+	}
+	if bytes.Contains(out.Bytes(), []byte("secret")) {
+		t.Errorf("xmp or comment remains")
+	}
+	if len(segments[0xe1]) != 1 {
+		t.Fatalf("APP1 segments = %d, want 1", len(segments[0xe1]))
+	}
+	exif := segments[0xe1][0]
+	if !bytes.HasPrefix(exif, jpegExifPrefix) {
+		t.Fatalf("APP1 is not exif")
+	}
+	tiff := exif[len(jpegExifPrefix):]
+	if orientation := exifOrientation(tiff); orientation != 6 {
+		t.Errorf("orientation = %d, want 6", orientation)
+	}
+	// IFD0にはOrientationだけが残り、GPS IFDへのポインタは落ちている
+	if entries := binary.BigEndian.Uint16(tiff[8:10]); entries != 1 {
+		t.Errorf("IFD0 entries = %d, want 1", entries)
+	}
+
+	if _, err := jpeg.Decode(bytes.NewReader(out.Bytes())); err != nil {
+		t.Errorf("failed to decode stripped jpeg: %v", err)
+	}
+}
+
+func TestStripJPEGMetadataWithoutOrientation(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, testImage(), nil); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+
+	var out bytes.Buffer
+	if _, err := copyWithoutImageMetadata(&out, bytes.NewReader(encoded.Bytes()), "image/jpeg"); err != nil {
+		t.Fatalf("failed to strip metadata: %v", err)
+	}
+	if len(jpegHeaderSegments(t, out.Bytes())[0xe1]) != 0 {
+		t.Errorf("exif added to image without orientation")
+	}
+}
+
+func TestStripJPEGMetadataTruncated(t *testing.T) {
+	input := testJPEGWithMetadata(t)
+
+	for _, size := range []int{0, 1, 3, 20, len(jpegExifPrefix) + 40} {
+		_, err := copyWithoutImageMetadata(&bytes.Buffer{}, bytes.NewReader(input[:size]), "image/jpeg")
+		if !errors.Is(err, errInvalidJPEG) {
+			t.Errorf("size %d: err = %v, want errInvalidJPEG", size, err)
+		}
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	input := testPNGWithMetadata(t)
+
+	var out bytes.Buffer
+	written, err := copyWithoutImageMetadata(&out, bytes.NewReader(input), "image/png")
+	if err != nil {
+		t.Fatalf("failed to strip metadata: %v", err)
+	}
+	if written != int64(out.Len()) {
+		t.Errorf("written = %d, want %d", written, out.Len())
+	}
+
+	for _, chunkType := range []string{"tEXt", "iTXt"} {
+		if bytes.Contains(out.Bytes(), []byte(chunkType)) {
+			t.Errorf("%s chunk remains", chunkType)
+		}
+	}
+	if bytes.Contains(out.Bytes(), []byte("secret")) {
+		t.Errorf("metadata remains")
+	}
+
+	if _, err := png.Decode(bytes.NewReader(out.Bytes())); err != nil {
+		t.Errorf("failed to decode stripped png: %v", err)
+	}
+}
+
+func TestStripPNGMetadataTruncated(t *testing.T) {
+	input := testPNGWithMetadata(t)
+
+	for _, size := range []int{0, 4, len(pngSignature) + 4, len(pngSignature) + 20, len(input) - 6} {
+		_, err := copyWithoutImageMetadata(&bytes.Buffer{}, bytes.NewReader(input[:size]), "image/png")
+		if !errors.Is(err, errInvalidPNG) {
+			t.Errorf("size %d: err = %v, want errInvalidPNG", size, err)
+		}
+	}
+}
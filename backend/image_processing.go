@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const (
+	imageVariantOriginal  = "original"
+	imageVariantLarge     = "large"
+	imageVariantMedium    = "medium"
+	imageVariantThumbnail = "thumbnail"
+
+	// 展開後のメモリ使用量を抑えるため、これを超える画素数の画像は受け付けない
+	maxImagePixels = 40_000_000
+	jpegQuality    = 85
+)
+
+type imageVariantSpec struct {
+	name  string
+	width int
+}
+
+// 大きい順に並べ、直前に作った縮小画像から次を作る
+var imageVariantSpecs = []imageVariantSpec{
+	{name: imageVariantLarge, width: 1600},
+	{name: imageVariantMedium, width: 800},
+	{name: imageVariantThumbnail, width: 320},
+}
+
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var (
+	errUnsupportedImage = errors.New("unsupported image type")
+	errImageDimensions  = errors.New("image dimensions too large")
+)
+
+type imageAnalysis struct {
+	width         int
+	height        int
+	dominantColor *string
+	// 縮小画像を作れない形式 (GIF・WebP) ではnil
+	pixels *image.RGBA
+}
+
+type imageVariant struct {
+	name     string
+	width    int
+	height   int
+	filePath string
+	mimeType string
+	fileSize int64
+}
+
+func isImageVariant(name string) bool {
+	if name == imageVariantOriginal {
+		return true
+	}
+	for _, spec := range imageVariantSpecs {
+		if spec.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func imageVariantWidth(name string) int {
+	for _, spec := range imageVariantSpecs {
+		if spec.name == name {
+			return spec.width
+		}
+	}
+	return 0
+}
+
+// 先頭のバイト列から形式を判定する。クライアントのContent-Typeや拡張子は使わない
+func sniffImageType(file io.ReadSeeker) (string, error) {
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	mimeType := http.DetectContentType(header[:n])
+	if _, ok := imageExtensions[mimeType]; !ok {
+		return "", errUnsupportedImage
+	}
+	return mimeType, nil
+}
+
+func analyzeImage(file io.ReadSeeker, mimeType string) (*imageAnalysis, error) {
+	if mimeType == "image/webp" {
+		width, height, err := webpDimensions(file)
+		if err != nil {
+			return nil, err
+		}
+		if width*height > maxImagePixels {
+			return nil, errImageDimensions
+		}
+		return &imageAnalysis{width: width, height: height}, nil
+	}
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, errUnsupportedImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, errImageDimensions
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	pixels := toRGBA(decoded)
+	dominantColor := averageColor(pixels)
+
+	analysis := &imageAnalysis{
+		width:         pixels.Rect.Dx(),
+		height:        pixels.Rect.Dy(),
+		dominantColor: &dominantColor,
+	}
+	// GIFはアニメーションを保つため縮小しない
+	if mimeType != "image/gif" {
+		analysis.pixels = pixels
+	}
+	return analysis, nil
+}
+
+// 元画像より小さいサイズだけ作る。作成したファイルは失敗時も含めて戻り値で返す
+func writeImageVariants(dir string, imageID string, mimeType string, analysis *imageAnalysis) ([]imageVariant, error) {
+	variants := make([]imageVariant, 0, len(imageVariantSpecs))
+	if analysis.pixels == nil {
+		return variants, nil
+	}
+
+	source := analysis.pixels
+	for _, spec := range imageVariantSpecs {
+		if spec.width >= analysis.width {
+			continue
+		}
+		height := int(math.Round(float64(analysis.height) * float64(spec.width) / float64(analysis.width)))
+		if height < 1 {
+			height = 1
+		}
+		resized := resizeRGBA(source, spec.width, height)
+		source = resized
+
+		name := fmt.Sprintf("%s_%s%s", imageID, spec.name, imageExtensions[mimeType])
+		size, err := writeEncodedImage(filepath.Join(dir, name), resized, mimeType)
+		if err != nil {
+			return variants, err
+		}
+		variants = append(variants, imageVariant{
+			name:     spec.name,
+			width:    spec.width,
+			height:   height,
+			filePath: name,
+			mimeType: mimeType,
+			fileSize: size,
+		})
+	}
+	return variants, nil
+}
+
+func writeEncodedImage(path string, img image.Image, mimeType string) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	switch mimeType {
+	case "image/jpeg":
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		err = png.Encode(file, img)
+	default:
+		err = errUnsupportedImage
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// 縮小専用。出力1画素に対応する範囲の平均を取る
+func resizeRGBA(src *image.RGBA, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	srcWidth, srcHeight := src.Rect.Dx(), src.Rect.Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max((y+1)*srcHeight/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max((x+1)*srcWidth/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[i])
+					g += uint64(src.Pix[i+1])
+					b += uint64(src.Pix[i+2])
+					a += uint64(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+func averageColor(src *image.RGBA) string {
+	pixel := resizeRGBA(src, 1, 1).Pix
+	r, g, b, a := uint32(pixel[0]), uint32(pixel[1]), uint32(pixel[2]), uint32(pixel[3])
+	if a > 0 && a < 255 {
+		r, g, b = r*255/a, g*255/a, b*255/a
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// 標準ライブラリにWebPのデコーダが無いため、寸法だけヘッダから読む
+func webpDimensions(file io.ReadSeeker) (int, int, error) {
+	header := make([]byte, 30)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return 0, 0, errUnsupportedImage
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	switch string(header[12:16]) {
+	case "VP8X":
+		width := int(header[24]) | int(header[25])<<8 | int(header[26])<<16
+		height := int(header[27]) | int(header[28])<<8 | int(header[29])<<16
+		return width + 1, height + 1, nil
+	case "VP8L":
+		if header[20] != 0x2f {
+			return 0, 0, errUnsupportedImage
+		}
+		bits := binary.LittleEndian.Uint32(header[21:25])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8 ":
+		if !bytes.Equal(header[23:26], []byte{0x9d, 0x01, 0x2a}) {
+			return 0, 0, errUnsupportedImage
+		}
+		width := int(binary.LittleEndian.Uint16(header[26:28]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(header[28:30]) & 0x3fff)
+		return width, height, nil
+	}
+	return 0, 0, errUnsupportedImage
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	imageTranscodeDir     = "derived"
+	imageTranscodeTimeout = 60 * time.Second
+)
+
+type imageTranscodeFormat struct {
+	name     string
+	mimeType string
+	command  string
+	args     func(src string, dst string) []string
+}
+
+// 優先したい順に並べる
+var imageTranscodeFormats = []imageTranscodeFormat{
+	{
+		name:     "avif",
+		mimeType: "image/avif",
+		command:  "avifenc",
+		args: func(src string, dst string) []string {
+			return []string{"--speed", "6", "--min", "20", "--max", "40", src, dst}
+		},
+	},
+	{
+		name:     "webp",
+		mimeType: "image/webp",
+		command:  "cwebp",
+		args: func(src string, dst string) []string {
+			return []string{"-quiet", "-q", "80", src, "-o", dst}
+		},
+	},
+}
+
+// WebP・AVIFへの変換結果をストレージのderived/配下にキャッシュする
+type imageTranscoder struct {
+	storage imageStorage
+	formats []imageTranscodeFormat
+	group   singleflight.Group
+}
+
+func createImageTranscoderFromEnv(storage imageStorage) *imageTranscoder {
+	enabled := map[string]bool{}
+	for _, name := range strings.Split(getEnv("IMAGE_TRANSCODE_FORMATS", "avif,webp"), ",") {
+		enabled[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	transcoder := &imageTranscoder{storage: storage}
+	for _, format := range imageTranscodeFormats {
+		if !enabled[format.name] {
+			continue
+		}
+		if _, err := exec.LookPath(format.command); err != nil {
+			log.Printf("[image transcode] %s is not available, %s disabled", format.command, format.name)
+			continue
+		}
+		transcoder.formats = append(transcoder.formats, format)
+	}
+	if len(transcoder.formats) == 0 {
+		return nil
+	}
+	return transcoder
+}
+
+func isTranscodableImage(mimeType string) bool {
+	return mimeType == "image/jpeg" || mimeType == "image/png"
+}
+
+// Acceptに明示された形式だけを対象にする (image/* や */* では変換しない)
+func acceptsImageType(accept string, mimeType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), mimeType) {
+			continue
+		}
+		for _, param := range params[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// 変換できない、または元より大きくなる場合はfalseを返す
+func (t *imageTranscoder) negotiate(ctx context.Context, accept string, source imageVariant) (imageVariant, bool) {
+	if t == nil || !isTranscodableImage(source.mimeType) {
+		return imageVariant{}, false
+	}
+
+	for _, format := range t.formats {
+		if !acceptsImageType(accept, format.mimeType) {
+			continue
+		}
+		derived, err := t.derive(ctx, format, source)
+		if err != nil {
+			log.Printf("[image transcode] %s to %s: %v", source.filePath, format.name, err)
+			continue
+		}
+		if derived.fileSize >= source.fileSize {
+			continue
+		}
+		return derived, true
+	}
+	return imageVariant{}, false
+}
+
+func derivedImageKey(key string, format string) string {
+	stem := strings.TrimSuffix(key, path.Ext(key))
+	return path.Join(imageTranscodeDir, stem+"."+format)
+}
+
+func (t *imageTranscoder) derive(ctx context.Context, format imageTranscodeFormat, source imageVariant) (imageVariant, error) {
+	derived := source
+	derived.filePath = derivedImageKey(source.filePath, format.name)
+	derived.mimeType = format.mimeType
+
+	if size, err := t.storage.stat(ctx, derived.filePath); err == nil {
+		derived.fileSize = size
+		return derived, nil
+	} else if !errors.Is(err, errImageObjectNotFound) {
+		return imageVariant{}, err
+	}
+
+	size, err, _ := t.group.Do(derived.filePath, func() (interface{}, error) {
+		// 要求元の切断で、同じ変換を待つ他のリクエストまで失敗しないようにする
+		ctx, cancel := context.WithTimeout(context.Background(), imageTranscodeTimeout)
+		defer cancel()
+		return t.transcode(ctx, format, source.filePath, derived.filePath)
+	})
+	if err != nil {
+		return imageVariant{}, err
+	}
+
+	derived.fileSize = size.(int64)
+	return derived, nil
+}
+
+// 外部コマンドはファイルを入出力にするため、作業ディレクトリを経由する
+func (t *imageTranscoder) transcode(ctx context.Context, format imageTranscodeFormat, srcKey string, dstKey string) (int64, error) {
+	workDir, err := os.MkdirTemp("", "image-transcode-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(workDir)
+
+	srcPath := filepath.Join(workDir, "source"+path.Ext(srcKey))
+	if err := downloadImageObject(ctx, t.storage, srcKey, srcPath); err != nil {
+		return 0, err
+	}
+
+	dstPath := filepath.Join(workDir, "derived."+format.name)
+	output, err := exec.CommandContext(ctx, format.command, format.args(srcPath, dstPath)...).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	info, err := os.Stat(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	if err := putImageFile(ctx, t.storage, dstKey, dstPath, format.mimeType); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"realtime/internal/query"
+	"realtime/internal/query/model"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const bulkImageUploadMaxFiles = 50
+
+type bulkImageUploadError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type bulkImageUploadResult struct {
+	FileName     string                `json:"file_name"`
+	Image        *model.CommonImage    `json:"image"`
+	Deduplicated bool                  `json:"deduplicated"`
+	Error        *bulkImageUploadError `json:"error"`
+}
+
+type bulkImageUploadResponse struct {
+	Results []bulkImageUploadResult `json:"results"`
+	Work    *workResponse           `json:"work,omitempty"`
+}
+
+// fileを複数受け取り、1件ずつ保存する。失敗したファイルがあっても他のファイルは保存する
+// work_idを指定すると、保存できた画像を送られた順で作品の画像一覧の末尾に追加する (既に付いている画像は追加しない)
+func (pSrv *server) handleBulkUploadImages(c echo.Context) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return invalidRequestBody()
+	}
+	fileHeaders := form.File["file"]
+	if len(fileHeaders) == 0 {
+		return newAPIError(http.StatusBadRequest, "image_file_required", "file is required")
+	}
+	if len(fileHeaders) > bulkImageUploadMaxFiles {
+		return newAPIError(http.StatusRequestEntityTooLarge, "too_many_files", "too many files")
+	}
+
+	ctx := c.Request().Context()
+
+	workID := strings.TrimSpace(c.FormValue("work_id"))
+	var expectedVersion *int32
+	if workID != "" {
+		expectedVersion, err = parseIfMatchVersion(c)
+		if err != nil {
+			return err
+		}
+		// 画像を保存する前に、追加先の作品を確認しておく
+		before, err := pSrv.fetchWorkSnapshot(ctx, workID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFound("work_not_found", "work not found")
+			}
+			return internalError("failed to fetch work", err)
+		}
+		if expectedVersion != nil && *expectedVersion != before.Version {
+			c.Response().Header().Set("ETag", workETag(before.Version))
+			return workVersionConflict()
+		}
+		setAuditTargetID(c, workID)
+		setAuditBefore(c, before)
+	}
+
+	results := make([]bulkImageUploadResult, 0, len(fileHeaders))
+	imageIDs := make([]string, 0, len(fileHeaders))
+	for _, fileHeader := range fileHeaders {
+		result := bulkImageUploadResult{FileName: fileHeader.Filename}
+
+		image, deduplicated, err := pSrv.storeUploadedImage(ctx, fileHeader)
+		if err != nil {
+			apiErr := toAPIError(err)
+			if apiErr.status >= http.StatusInternalServerError {
+				log.Printf("[bulk upload] %s: %v", fileHeader.Filename, err)
+			}
+			result.Error = &bulkImageUploadError{Code: apiErr.code, Message: apiErr.message}
+		} else {
+			result.Image = image
+			result.Deduplicated = deduplicated
+			imageIDs = append(imageIDs, *image.ID)
+		}
+		results = append(results, result)
+	}
+
+	response := bulkImageUploadResponse{Results: results}
+	if workID != "" && len(imageIDs) > 0 {
+		if err := pSrv.q.Transaction(func(tx *query.Query) error {
+			if err := pSrv.recordWorkRevisionBaseline(ctx, tx, workID); err != nil {
+				return err
+			}
+			if err := updateWorkWithVersion(ctx, tx, workID, expectedVersion, map[string]interface{}{
+				"version": gorm.Expr("version + 1"),
+			}); err != nil {
+				return err
+			}
+			if err := appendWorkImages(ctx, tx, workID, imageIDs); err != nil {
+				return err
+			}
+			return pSrv.recordWorkRevision(ctx, tx, workID, adminEmail(c))
+		}); err != nil {
+			if errors.Is(err, errWorkVersionConflict) {
+				return workVersionConflict()
+			}
+			return internalError("failed to attach images to work", err)
+		}
+
+		after, err := pSrv.fetchWorkSnapshot(ctx, workID)
+		if err != nil {
+			return internalError("failed to fetch work", err)
+		}
+		setAuditAfter(c, after)
+		response.Work = after
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// 作品の行はupdateWorkWithVersionでロックされているため、表示順が重複することはない
+// 重複排除で既存の画像が返った場合や同じファイルが複数送られた場合に備え、作品に付いている画像は追加しない
+func appendWorkImages(ctx context.Context, tx *query.Query, workID string, imageIDs []string) error {
+	var attachedIDs []string
+	if err := tx.IsirmtWorkImage.WithContext(ctx).
+		Where(tx.IsirmtWorkImage.WorkID.Eq(workID)).
+		Pluck(tx.IsirmtWorkImage.ImageID, &attachedIDs); err != nil {
+		return err
+	}
+	skip := make(map[string]struct{}, len(attachedIDs)+len(imageIDs))
+	for _, id := range attachedIDs {
+		skip[id] = struct{}{}
+	}
+	newIDs := make([]string, 0, len(imageIDs))
+	for _, id := range imageIDs {
+		if _, ok := skip[id]; ok {
+			continue
+		}
+		skip[id] = struct{}{}
+		newIDs = append(newIDs, id)
+	}
+	if len(newIDs) == 0 {
+		return nil
+	}
+
+	var nextOrder int32
+	if err := tx.IsirmtWorkImage.WithContext(ctx).UnderlyingDB().Raw(
+		`SELECT COALESCE(MAX(display_order) + 1, 0) FROM isirmt_work_images WHERE work_id = ?`,
+		workID,
+	).Scan(&nextOrder).Error; err != nil {
+		return err
+	}
+
+	images := make([]*model.IsirmtWorkImage, 0, len(newIDs))
+	for index, imageID := range newIDs {
+		images = append(images, &model.IsirmtWorkImage{
+			WorkID:       workID,
+			ImageID:      imageID,
+			DisplayOrder: nextOrder + int32(index),
+		})
+	}
+	return tx.IsirmtWorkImage.WithContext(ctx).Create(images...)
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// uploadDir直下に置く。ドットで始まるディレクトリはストレージの一覧から除外される
+	resumableUploadDir         = ".uploads"
+	resumableUploadExpiry      = 24 * time.Hour
+	resumableUploadContentType = "application/offset+octet-stream"
+	resumableUploadInfoFile    = "info.json"
+	resumableUploadDataFile    = "data"
+)
+
+var (
+	errResumableUploadNotFound = errors.New("upload not found")
+	errResumableUploadBusy     = errors.New("upload is being written by another request")
+	errResumableUploadOffset   = errors.New("upload offset does not match")
+	errResumableUploadOverflow = errors.New("upload exceeds declared length")
+)
+
+type resumableUploadInfo struct {
+	ID        string    `json:"id"`
+	FileName  string    `json:"file_name"`
+	Length    int64     `json:"length"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type resumableUploadResponse struct {
+	ID        string    `json:"id"`
+	FileName  string    `json:"file_name"`
+	Length    int64     `json:"length"`
+	Offset    int64     `json:"offset"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// 再開可能なアップロードの途中データ。<dir>/<id>/ にinfo.jsonとdataを置く
+type resumableUploads struct {
+	dir  string
+	mu   sync.Mutex
+	busy map[string]struct{}
+}
+
+func createResumableUploads(uploadDir string) *resumableUploads {
+	return &resumableUploads{
+		dir:  filepath.Join(uploadDir, resumableUploadDir),
+		busy: map[string]struct{}{},
+	}
+}
+
+func (u *resumableUploads) path(id string, name string) string {
+	return filepath.Join(u.dir, id, name)
+}
+
+// 同じアップロードへの書き込みが重なると位置がずれるため、1リクエストずつにする
+func (u *resumableUploads) lock(id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.busy[id]; ok {
+		return errResumableUploadBusy
+	}
+	u.busy[id] = struct{}{}
+	return nil
+}
+
+func (u *resumableUploads) unlock(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.busy, id)
+}
+
+func (u *resumableUploads) create(fileName string, length int64) (*resumableUploadInfo, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	info := &resumableUploadInfo{
+		ID:        id.String(),
+		FileName:  fileName,
+		Length:    length,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := os.MkdirAll(filepath.Join(u.dir, info.ID), 0o755); err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(u.path(info.ID, resumableUploadInfoFile), payload, 0o644); err != nil {
+		_ = u.remove(info.ID)
+		return nil, err
+	}
+	if err := os.WriteFile(u.path(info.ID, resumableUploadDataFile), nil, 0o644); err != nil {
+		_ = u.remove(info.ID)
+		return nil, err
+	}
+	return info, nil
+}
+
+// 受け取り済みのバイト数 (データファイルのサイズ) も返す
+func (u *resumableUploads) load(id string) (*resumableUploadInfo, int64, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, 0, errResumableUploadNotFound
+	}
+
+	payload, err := os.ReadFile(u.path(id, resumableUploadInfoFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, errResumableUploadNotFound
+		}
+		return nil, 0, err
+	}
+	var info resumableUploadInfo
+	if err := json.Unmarshal(payload, &info); err != nil {
+		return nil, 0, err
+	}
+
+	stat, err := os.Stat(u.path(id, resumableUploadDataFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, errResumableUploadNotFound
+		}
+		return nil, 0, err
+	}
+	return &info, stat.Size(), nil
+}
+
+// offsetの位置から書き足し、新しいoffsetを返す
+// 途中で切断された場合も、受け取れた分は残して続きから再開できるようにする
+func (u *resumableUploads) write(info *resumableUploadInfo, offset int64, body io.Reader) (int64, error) {
+	file, err := os.OpenFile(u.path(info.ID, resumableUploadDataFile), os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if stat.Size() != offset {
+		return stat.Size(), errResumableUploadOffset
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	remaining := info.Length - offset
+	written, err := io.Copy(file, io.LimitReader(body, remaining+1))
+	if written > remaining {
+		if truncateErr := file.Truncate(offset); truncateErr != nil {
+			return offset, truncateErr
+		}
+		return offset, errResumableUploadOverflow
+	}
+	return offset + written, err
+}
+
+func (u *resumableUploads) remove(id string) error {
+	return os.RemoveAll(filepath.Join(u.dir, id))
+}
+
+func (u *resumableUploads) removeExpired(before time.Time) error {
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, _, err := u.load(entry.Name())
+		if err != nil && !errors.Is(err, errResumableUploadNotFound) {
+			log.Printf("[resumable upload] failed to load %s: %v", entry.Name(), err)
+			continue
+		}
+		if info != nil && info.CreatedAt.After(before) {
+			continue
+		}
+		// 書き込み中のものは次の機会に回す
+		if err := u.lock(entry.Name()); err != nil {
+			continue
+		}
+		err = u.remove(entry.Name())
+		u.unlock(entry.Name())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pSrv *server) startResumableUploadCleanup(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := pSrv.uploads.removeExpired(time.Now().Add(-resumableUploadExpiry)); err != nil {
+				log.Printf("[resumable upload] %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func buildResumableUploadResponse(info *resumableUploadInfo, offset int64) resumableUploadResponse {
+	return resumableUploadResponse{
+		ID:        info.ID,
+		FileName:  info.FileName,
+		Length:    info.Length,
+		Offset:    offset,
+		CreatedAt: info.CreatedAt,
+		ExpiresAt: info.CreatedAt.Add(resumableUploadExpiry),
+	}
+}
+
+func setResumableUploadHeaders(c echo.Context, info *resumableUploadInfo, offset int64) {
+	header := c.Response().Header()
+	header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	header.Set("Cache-Control", "no-store")
+}
+
+// tusのUpload-Metadata (key base64value,key base64value) からファイル名を取り出す
+func parseUploadFileName(raw string) (string, error) {
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key != "filename" && key != "file_name" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return "", err
+		}
+		return string(decoded), nil
+	}
+	return "", nil
+}
+
+func (pSrv *server) loadResumableUpload(id string) (*resumableUploadInfo, int64, error) {
+	info, offset, err := pSrv.uploads.load(id)
+	if err != nil {
+		if errors.Is(err, errResumableUploadNotFound) {
+			return nil, 0, notFound("upload_not_found", "upload not found")
+		}
+		return nil, 0, internalError("failed to load upload", err)
+	}
+	return info, offset, nil
+}
+
+func (pSrv *server) lockResumableUpload(id string) error {
+	if err := pSrv.uploads.lock(id); err != nil {
+		return newAPIError(http.StatusConflict, "upload_in_progress", "upload is being written by another request").withCause(err)
+	}
+	return nil
+}
+
+// Upload-Lengthで全体のサイズを、Upload-Metadataでファイル名を受け取る
+func (pSrv *server) handleCreateImageUpload(c echo.Context) error {
+	length, err := strconv.ParseInt(strings.TrimSpace(c.Request().Header.Get("Upload-Length")), 10, 64)
+	if err != nil || length <= 0 {
+		return invalidParameter("Upload-Length", "Upload-Length must be positive number")
+	}
+	if length > int64(pSrv.maxUploadSize) {
+		return newAPIError(http.StatusRequestEntityTooLarge, "image_too_large", "file too large")
+	}
+
+	fileName, err := parseUploadFileName(c.Request().Header.Get("Upload-Metadata"))
+	if err != nil {
+		return invalidParameter("Upload-Metadata", "Upload-Metadata values must be base64 encoded")
+	}
+	if strings.TrimSpace(fileName) == "" {
+		fileName = "upload"
+	}
+
+	info, err := pSrv.uploads.create(fileName, length)
+	if err != nil {
+		return internalError("failed to create upload", err)
+	}
+
+	setAuditTargetID(c, info.ID)
+	setResumableUploadHeaders(c, info, 0)
+	c.Response().Header().Set("Location", "/images/uploads/"+info.ID)
+	return c.JSON(http.StatusCreated, buildResumableUploadResponse(info, 0))
+}
+
+// HEADではヘッダーのみ、GETでは同じ内容をJSONでも返す
+func (pSrv *server) handleGetImageUpload(c echo.Context) error {
+	info, offset, err := pSrv.loadResumableUpload(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	setResumableUploadHeaders(c, info, offset)
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(http.StatusOK)
+	}
+	return c.JSON(http.StatusOK, buildResumableUploadResponse(info, offset))
+}
+
+// Upload-Offsetは受け取り済みのバイト数と一致している必要がある
+func (pSrv *server) handlePatchImageUpload(c echo.Context) error {
+	mediaType := strings.TrimSpace(strings.Split(c.Request().Header.Get(echo.HeaderContentType), ";")[0])
+	if mediaType != resumableUploadContentType {
+		return newAPIError(http.StatusUnsupportedMediaType, "unsupported_content_type", "Content-Type must be "+resumableUploadContentType)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(c.Request().Header.Get("Upload-Offset")), 10, 64)
+	if err != nil || offset < 0 {
+		return invalidParameter("Upload-Offset", "Upload-Offset must be non-negative number")
+	}
+
+	id := c.Param("id")
+	info, _, err := pSrv.loadResumableUpload(id)
+	if err != nil {
+		return err
+	}
+	if err := pSrv.lockResumableUpload(id); err != nil {
+		return err
+	}
+	defer pSrv.uploads.unlock(id)
+
+	newOffset, err := pSrv.uploads.write(info, offset, c.Request().Body)
+	setResumableUploadHeaders(c, info, newOffset)
+	if err != nil {
+		switch {
+		case errors.Is(err, errResumableUploadOffset):
+			return newAPIError(http.StatusConflict, "upload_offset_mismatch", "Upload-Offset does not match the received size").withCause(err)
+		case errors.Is(err, errResumableUploadOverflow):
+			return newAPIError(http.StatusRequestEntityTooLarge, "upload_too_large", "chunk exceeds Upload-Length").withCause(err)
+		case newOffset > offset:
+			return newAPIError(http.StatusBadRequest, "upload_interrupted", "upload was interrupted, resume from Upload-Offset").withCause(err)
+		default:
+			return internalError("failed to write upload", err)
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// 全体を受け取り終えたアップロードを画像として登録する
+func (pSrv *server) handleFinalizeImageUpload(c echo.Context) error {
+	id := c.Param("id")
+	info, _, err := pSrv.loadResumableUpload(id)
+	if err != nil {
+		return err
+	}
+	if err := pSrv.lockResumableUpload(id); err != nil {
+		return err
+	}
+	defer pSrv.uploads.unlock(id)
+
+	file, err := os.Open(pSrv.uploads.path(id, resumableUploadDataFile))
+	if err != nil {
+		return internalError("failed to open upload", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return internalError("failed to open upload", err)
+	}
+	if stat.Size() != info.Length {
+		setResumableUploadHeaders(c, info, stat.Size())
+		return newAPIError(http.StatusConflict, "upload_incomplete", "upload has not received all bytes yet")
+	}
+
+	newImage, deduplicated, err := pSrv.storeImageFile(c.Request().Context(), info.FileName, file)
+	if err != nil {
+		return err
+	}
+
+	if err := pSrv.uploads.remove(id); err != nil {
+		log.Printf("[resumable upload] failed to remove %s: %v", id, err)
+	}
+
+	setAuditTargetID(c, *newImage.ID)
+	return c.JSON(200, uploadedImageResponse{
+		CommonImage:  newImage,
+		Deduplicated: deduplicated,
+	})
+}
+
+func (pSrv *server) handleDeleteImageUpload(c echo.Context) error {
+	id := c.Param("id")
+	if _, _, err := pSrv.loadResumableUpload(id); err != nil {
+		return err
+	}
+	if err := pSrv.lockResumableUpload(id); err != nil {
+		return err
+	}
+	defer pSrv.uploads.unlock(id)
+
+	if err := pSrv.uploads.remove(id); err != nil {
+		return internalError("failed to remove upload", err)
+	}
+
+	setAuditTargetID(c, id)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -34,6 +34,14 @@ func newCommonImage(db *gorm.DB, opts ...gen.DOOption) commonImage {
 	_commonImage.MimeType = field.NewString(tableName, "mime_type")
 	_commonImage.FileSize = field.NewInt64(tableName, "file_size")
 	_commonImage.UploadedAt = field.NewTime(tableName, "uploaded_at")
+	_commonImage.Width = field.NewInt32(tableName, "width")
+	_commonImage.Height = field.NewInt32(tableName, "height")
+	_commonImage.DominantColor = field.NewString(tableName, "dominant_color")
+	_commonImage.ContentHash = field.NewString(tableName, "content_hash")
+	_commonImage.AltText = field.NewString(tableName, "alt_text")
+	_commonImage.Caption = field.NewString(tableName, "caption")
+	_commonImage.Credit = field.NewString(tableName, "credit")
+	_commonImage.DuplicateOfImageID = field.NewString(tableName, "duplicate_of_image_id")
 
 	_commonImage.fillFieldMap()
 
@@ -43,13 +51,21 @@ func newCommonImage(db *gorm.DB, opts ...gen.DOOption) commonImage {
 type commonImage struct {
 	commonImageDo commonImageDo
 
-	ALL        field.Asterisk
-	ID         field.String
-	FileName   field.String
-	FilePath   field.String
-	MimeType   field.String
-	FileSize   field.Int64
-	UploadedAt field.Time
+	ALL                field.Asterisk
+	ID                 field.String
+	FileName           field.String
+	FilePath           field.String
+	MimeType           field.String
+	FileSize           field.Int64
+	UploadedAt         field.Time
+	Width              field.Int32
+	Height             field.Int32
+	DominantColor      field.String
+	ContentHash        field.String
+	AltText            field.String
+	Caption            field.String
+	Credit             field.String
+	DuplicateOfImageID field.String
 
 	fieldMap map[string]field.Expr
 }
@@ -72,6 +88,14 @@ func (c *commonImage) updateTableName(table string) *commonImage {
 	c.MimeType = field.NewString(table, "mime_type")
 	c.FileSize = field.NewInt64(table, "file_size")
 	c.UploadedAt = field.NewTime(table, "uploaded_at")
+	c.Width = field.NewInt32(table, "width")
+	c.Height = field.NewInt32(table, "height")
+	c.DominantColor = field.NewString(table, "dominant_color")
+	c.ContentHash = field.NewString(table, "content_hash")
+	c.AltText = field.NewString(table, "alt_text")
+	c.Caption = field.NewString(table, "caption")
+	c.Credit = field.NewString(table, "credit")
+	c.DuplicateOfImageID = field.NewString(table, "duplicate_of_image_id")
 
 	c.fillFieldMap()
 
@@ -98,13 +122,21 @@ func (c *commonImage) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (c *commonImage) fillFieldMap() {
-	c.fieldMap = make(map[string]field.Expr, 6)
+	c.fieldMap = make(map[string]field.Expr, 14)
 	c.fieldMap["id"] = c.ID
 	c.fieldMap["file_name"] = c.FileName
 	c.fieldMap["file_path"] = c.FilePath
 	c.fieldMap["mime_type"] = c.MimeType
 	c.fieldMap["file_size"] = c.FileSize
 	c.fieldMap["uploaded_at"] = c.UploadedAt
+	c.fieldMap["width"] = c.Width
+	c.fieldMap["height"] = c.Height
+	c.fieldMap["dominant_color"] = c.DominantColor
+	c.fieldMap["content_hash"] = c.ContentHash
+	c.fieldMap["alt_text"] = c.AltText
+	c.fieldMap["caption"] = c.Caption
+	c.fieldMap["credit"] = c.Credit
+	c.fieldMap["duplicate_of_image_id"] = c.DuplicateOfImageID
 }
 
 func (c commonImage) clone(db *gorm.DB) commonImage {
@@ -38,6 +38,12 @@ func newIsirmtWork(db *gorm.DB, opts ...gen.DOOption) isirmtWork {
 	_isirmtWork.SearchDirty = field.NewBool(tableName, "search_dirty")
 	_isirmtWork.SearchIndexedAt = field.NewTime(tableName, "search_indexed_at")
 	_isirmtWork.SearchIndexError = field.NewString(tableName, "search_index_error")
+	_isirmtWork.Slug = field.NewString(tableName, "slug")
+	_isirmtWork.DeletedAt = field.NewField(tableName, "deleted_at")
+	_isirmtWork.Status = field.NewString(tableName, "status")
+	_isirmtWork.PublishAt = field.NewTime(tableName, "publish_at")
+	_isirmtWork.UpdatedAt = field.NewTime(tableName, "updated_at")
+	_isirmtWork.Version = field.NewInt32(tableName, "version")
 	_isirmtWork.WorkImages = isirmtWorkHasManyWorkImages{
 		db: db.Session(&gorm.Session{}),
 
@@ -86,6 +92,12 @@ type isirmtWork struct {
 	SearchDirty      field.Bool
 	SearchIndexedAt  field.Time
 	SearchIndexError field.String
+	Slug             field.String
+	DeletedAt        field.Field
+	Status           field.String
+	PublishAt        field.Time
+	UpdatedAt        field.Time
+	Version          field.Int32
 	WorkImages       isirmtWorkHasManyWorkImages
 
 	URLs isirmtWorkHasManyURLs
@@ -119,6 +131,12 @@ func (i *isirmtWork) updateTableName(table string) *isirmtWork {
 	i.SearchDirty = field.NewBool(table, "search_dirty")
 	i.SearchIndexedAt = field.NewTime(table, "search_indexed_at")
 	i.SearchIndexError = field.NewString(table, "search_index_error")
+	i.Slug = field.NewString(table, "slug")
+	i.DeletedAt = field.NewField(table, "deleted_at")
+	i.Status = field.NewString(table, "status")
+	i.PublishAt = field.NewTime(table, "publish_at")
+	i.UpdatedAt = field.NewTime(table, "updated_at")
+	i.Version = field.NewInt32(table, "version")
 
 	i.fillFieldMap()
 
@@ -145,7 +163,7 @@ func (i *isirmtWork) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (i *isirmtWork) fillFieldMap() {
-	i.fieldMap = make(map[string]field.Expr, 14)
+	i.fieldMap = make(map[string]field.Expr, 20)
 	i.fieldMap["id"] = i.ID
 	i.fieldMap["title"] = i.Title
 	i.fieldMap["comment"] = i.Comment
@@ -156,6 +174,12 @@ func (i *isirmtWork) fillFieldMap() {
 	i.fieldMap["search_dirty"] = i.SearchDirty
 	i.fieldMap["search_indexed_at"] = i.SearchIndexedAt
 	i.fieldMap["search_index_error"] = i.SearchIndexError
+	i.fieldMap["slug"] = i.Slug
+	i.fieldMap["deleted_at"] = i.DeletedAt
+	i.fieldMap["status"] = i.Status
+	i.fieldMap["publish_at"] = i.PublishAt
+	i.fieldMap["updated_at"] = i.UpdatedAt
+	i.fieldMap["version"] = i.Version
 
 }
 
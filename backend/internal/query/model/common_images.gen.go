@@ -12,12 +12,20 @@ const TableNameCommonImage = "common_images"
 
 // CommonImage mapped from table <common_images>
 type CommonImage struct {
-	ID         *string    `gorm:"column:id;type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	FileName   string     `gorm:"column:file_name;type:text;not null" json:"file_name"`
-	FilePath   string     `gorm:"column:file_path;type:text;not null" json:"file_path"`
-	MimeType   string     `gorm:"column:mime_type;type:text;not null" json:"mime_type"`
-	FileSize   int64      `gorm:"column:file_size;type:bigint;not null" json:"file_size"`
-	UploadedAt *time.Time `gorm:"column:uploaded_at;type:timestamp with time zone;not null;default:now()" json:"uploaded_at"`
+	ID                 *string    `gorm:"column:id;type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	FileName           string     `gorm:"column:file_name;type:text;not null" json:"file_name"`
+	FilePath           string     `gorm:"column:file_path;type:text;not null" json:"file_path"`
+	MimeType           string     `gorm:"column:mime_type;type:text;not null" json:"mime_type"`
+	FileSize           int64      `gorm:"column:file_size;type:bigint;not null" json:"file_size"`
+	UploadedAt         *time.Time `gorm:"column:uploaded_at;type:timestamp with time zone;not null;default:now()" json:"uploaded_at"`
+	Width              *int32     `gorm:"column:width;type:integer" json:"width"`
+	Height             *int32     `gorm:"column:height;type:integer" json:"height"`
+	DominantColor      *string    `gorm:"column:dominant_color;type:text" json:"dominant_color"`
+	ContentHash        *string    `gorm:"column:content_hash;type:text;uniqueIndex:uq_common_images_content_hash,priority:1" json:"content_hash"`
+	AltText            *string    `gorm:"column:alt_text;type:text" json:"alt_text"`
+	Caption            *string    `gorm:"column:caption;type:text" json:"caption"`
+	Credit             *string    `gorm:"column:credit;type:text" json:"credit"`
+	DuplicateOfImageID *string    `gorm:"column:duplicate_of_image_id;type:uuid" json:"duplicate_of_image_id"`
 }
 
 // TableName CommonImage's table name
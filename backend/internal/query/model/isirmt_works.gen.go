@@ -6,6 +6,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 const TableNameIsirmtWork = "isirmt_works"
@@ -22,6 +24,12 @@ type IsirmtWork struct {
 	SearchDirty      *bool              `gorm:"column:search_dirty;type:boolean;not null;default:true" json:"search_dirty"`
 	SearchIndexedAt  *time.Time         `gorm:"column:search_indexed_at;type:timestamp with time zone" json:"search_indexed_at"`
 	SearchIndexError *string            `gorm:"column:search_index_error;type:text" json:"search_index_error"`
+	Slug             *string            `gorm:"column:slug;type:text;uniqueIndex:uq_isirmt_works_slug,priority:1" json:"slug"`
+	DeletedAt        gorm.DeletedAt     `gorm:"column:deleted_at;type:timestamp with time zone;index:idx_isirmt_works_deleted_at,priority:1" json:"deleted_at"`
+	Status           string             `gorm:"column:status;type:text;not null;index:idx_isirmt_works_status_publish_at,priority:1;default:published" json:"status"`
+	PublishAt        *time.Time         `gorm:"column:publish_at;type:timestamp with time zone;index:idx_isirmt_works_status_publish_at,priority:2" json:"publish_at"`
+	UpdatedAt        *time.Time         `gorm:"column:updated_at;type:timestamp with time zone;not null;default:now()" json:"updated_at"`
+	Version          int32              `gorm:"column:version;type:integer;not null;default:1" json:"version"`
 	WorkImages       []*IsirmtWorkImage `gorm:"foreignKey:WorkID;references:ID" json:"images"`
 	URLs             []*IsirmtWorkURL   `gorm:"foreignKey:WorkID;references:ID" json:"urls"`
 	TechStacks       []*CommonTechStack `gorm:"joinForeignKey:WorkID;joinReferences:TechStackID;many2many:isirmt_work_tech_stacks" json:"tech_stacks"`
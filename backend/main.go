@@ -17,6 +17,13 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate-images" {
+		if err := runImageMigration(os.Args[2:]); err != nil {
+			log.Fatalf("failed to migrate images. %v", err)
+		}
+		return
+	}
+
 	dbUrl := os.Getenv("DATABASE_URL")
 	if dbUrl == "" {
 		log.Fatalf("dbUrl isn't set.")
@@ -54,6 +61,13 @@ func main() {
 		log.Fatalf("ADMIN_SECRET isn't set.")
 	}
 
+	adminAllowedEmails := parseAllowedEmails(os.Getenv("ADMIN_ALLOWED_EMAILS"))
+	if len(adminAllowedEmails) == 0 {
+		log.Fatalf("ADMIN_ALLOWED_EMAILS isn't set.")
+	}
+
+	storage := createImageStorageFromEnv(uploadDir)
+
 	pSrv := &server{
 		db:                   gormDb,
 		q:                    query.Use(gormDb),
@@ -61,15 +75,40 @@ func main() {
 		allowedOrigin:        os.Getenv("ALLOWED_ORIGIN"),
 		maxUploadSize:        20 << 20, // 20 MiB
 		adminSecret:          adminSecret,
+		adminAllowedEmails:   adminAllowedEmails,
 		embeddingBaseURL:     strings.TrimRight(os.Getenv("EMBEDDING_BASE_URL"), "/"),
 		searchEmbeddingModel: getEnv("SEARCH_EMBEDDING_MODEL", "intfloat/multilingual-e5-small"),
 		httpClient:           &http.Client{Timeout: 60 * time.Second},
-		clickLimiter:         createClickLimiter(2*time.Second, 10000, time.Minute),
+		clickLimiter:         createClickLimiterFromEnv(gormDb),
+		adminAuthLimiter:     createRateLimiter(adminAuthFailurePolicy),
 		wsHub:                createWsHub(),
+		storage:              storage,
+		imageURLTTL:          time.Duration(getEnvInt("IMAGE_URL_TTL_SECONDS", 0)) * time.Second,
+		imageTranscoder:      createImageTranscoderFromEnv(storage),
+		uploads:              createResumableUploads(uploadDir),
 	}
 
+	pSrv.startClickRollup(
+		ctx,
+		time.Duration(getEnvInt("CLICK_ROLLUP_INTERVAL_SECONDS", 300))*time.Second,
+		time.Duration(getEnvInt("CLICK_RETENTION_DAYS", 180))*24*time.Hour,
+	)
+
+	pSrv.startWorkPublisher(ctx, time.Duration(getEnvInt("WORK_PUBLISH_INTERVAL_SECONDS", 60))*time.Second)
+
+	pSrv.startResumableUploadCleanup(ctx, time.Hour)
+
+	go pSrv.backfillImageContentHashes(ctx)
+
 	router := echo.New()
+	router.HTTPErrorHandler = pSrv.handleHTTPError
 	router.HideBanner = true
+	ipExtractor, err := createIPExtractor(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("failed to parse TRUSTED_PROXIES. %v", err)
+	}
+	router.IPExtractor = ipExtractor
+	router.Use(middleware.RequestID())
 	router.Use(middleware.Logger())
 	router.Use(middleware.Recover())
 	router.Use(middleware.CORSWithConfig(corsConfig(pSrv.allowedOrigin)))
@@ -78,26 +117,57 @@ func main() {
 
 	router.GET("/ws", pSrv.handleWS)
 
-	epImages := router.Group("/images")
+	epImages := router.Group("/images", pSrv.rateLimit(createRateLimiter(assetRateLimitPolicy)))
 	epImages.GET("", pSrv.handleGetImages)
 	epImages.POST("", pSrv.requireAdmin(pSrv.handleUploadImage))
+	epImages.POST("/bulk", pSrv.requireAdmin(pSrv.handleBulkUploadImages))
+	epImages.POST("/uploads", pSrv.requireAdmin(pSrv.handleCreateImageUpload))
+	epImages.GET("/uploads/:id", pSrv.requireAdmin(pSrv.handleGetImageUpload))
+	epImages.HEAD("/uploads/:id", pSrv.requireAdmin(pSrv.handleGetImageUpload))
+	epImages.PATCH("/uploads/:id", pSrv.requireAdmin(pSrv.handlePatchImageUpload))
+	epImages.POST("/uploads/:id/finalize", pSrv.requireAdmin(pSrv.handleFinalizeImageUpload))
+	epImages.DELETE("/uploads/:id", pSrv.requireAdmin(pSrv.handleDeleteImageUpload))
 	epImages.GET("/:id", pSrv.handleGetImage)
 	epImages.GET("/:id/raw", pSrv.handleServeImage)
+	epImages.PATCH("/:id", pSrv.requireAdmin(pSrv.handlePatchImage))
 	epImages.DELETE("/:id", pSrv.requireAdmin(pSrv.handleDeleteImage))
 
-	epTechStacks := router.Group("/tech-stacks")
+	publicLimiter := createRateLimiter(publicRateLimitPolicy)
+
+	epTechStacks := router.Group("/tech-stacks", pSrv.rateLimit(publicLimiter))
 	epTechStacks.GET("", pSrv.handleGetTechStacks)
 	epTechStacks.GET("/:id", pSrv.handleGetTechStack)
 	epTechStacks.POST("", pSrv.requireAdmin(pSrv.handleCreateTechStack))
 
-	epWorks := router.Group("/works")
+	epWorks := router.Group("/works", pSrv.rateLimit(publicLimiter))
 	epWorks.GET("", pSrv.handleGetWorks)
 	epWorks.GET("/ranking", pSrv.handleGetRankingWorks)
-	epWorks.GET("/search", pSrv.handleSearchWorks)
+	epWorks.GET("/search", pSrv.handleSearchWorks, pSrv.rateLimit(createRateLimiter(searchRateLimitPolicy)))
+	epWorks.GET("/by-slug/:slug", pSrv.handleGetWorkBySlug)
+	epWorks.GET("/analytics/clicks", pSrv.requireAdmin(pSrv.handleGetClickSeries))
+	epWorks.GET("/analytics/totals", pSrv.requireAdmin(pSrv.handleGetClickTotals))
+	epWorks.GET("/analytics/movers", pSrv.requireAdmin(pSrv.handleGetClickMovers))
+	epWorks.GET("/trash", pSrv.requireAdmin(pSrv.handleGetTrashedWorks))
+	epWorks.GET("/preview", pSrv.requireAdmin(pSrv.handleGetPreviewWorks))
+	epWorks.GET("/preview/:id", pSrv.requireAdmin(pSrv.handleGetPreviewWork))
+	epWorks.GET("/:id", pSrv.handleGetWork)
 	epWorks.POST("", pSrv.requireAdmin(pSrv.handleCreateWork))
 	epWorks.POST("/:id/clicks", pSrv.handleCreateWorkClick)
 	epWorks.PUT("/:id", pSrv.requireAdmin(pSrv.handleUpdateWork))
+	epWorks.PATCH("/:id", pSrv.requireAdmin(pSrv.handlePatchWork))
 	epWorks.DELETE("/:id", pSrv.requireAdmin(pSrv.handleDeleteWork))
+	epWorks.POST("/trash/:id/restore", pSrv.requireAdmin(pSrv.handleRestoreWork))
+	epWorks.GET("/:id/revisions", pSrv.requireAdmin(pSrv.handleGetWorkRevisions))
+	epWorks.GET("/:id/revisions/diff", pSrv.requireAdmin(pSrv.handleDiffWorkRevisions))
+	epWorks.GET("/:id/revisions/:revision", pSrv.requireAdmin(pSrv.handleGetWorkRevision))
+	epWorks.POST("/:id/revisions/:revision/rollback", pSrv.requireAdmin(pSrv.handleRollbackWork))
+	epWorks.DELETE("/trash/:id", pSrv.requireAdmin(pSrv.handlePurgeWork))
+
+	epAdmin := router.Group("/admin", pSrv.rateLimit(publicLimiter))
+	epAdmin.GET("/audit", pSrv.requireAdmin(pSrv.handleGetAuditLogs))
+	epAdmin.GET("/images", pSrv.requireAdmin(pSrv.handleGetImageLibrary))
+	epAdmin.GET("/images/gc", pSrv.requireAdmin(pSrv.handleImageGC))
+	epAdmin.POST("/images/gc", pSrv.requireAdmin(pSrv.handleImageGC))
 
 	addr := getEnv("HOST", "0.0.0.0") + ":" + getEnv("PORT", "4000")
 	log.Printf("backend listening on %s", addr)
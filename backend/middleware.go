@@ -1,27 +1,207 @@
 package main
 
 import (
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
 )
 
+type rateLimitPolicy struct {
+	limit rate.Limit
+	burst int
+}
+
+var (
+	publicRateLimitPolicy = rateLimitPolicy{limit: 10, burst: 40}
+	assetRateLimitPolicy  = rateLimitPolicy{limit: 50, burst: 200}
+	searchRateLimitPolicy = rateLimitPolicy{limit: 0.5, burst: 5}
+	// 管理者認証の失敗は10分あたり5回まで
+	adminAuthFailurePolicy = rateLimitPolicy{limit: rate.Every(2 * time.Minute), burst: 5}
+)
+
+type rateVisitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type rateLimiter struct {
+	mu              sync.Mutex
+	policy          rateLimitPolicy
+	visitors        map[string]*rateVisitor
+	maxEntries      int
+	cleanupInterval time.Duration
+	lastCleanup     time.Time
+}
+
+func createRateLimiter(policy rateLimitPolicy) *rateLimiter {
+	return &rateLimiter{
+		policy:          policy,
+		visitors:        make(map[string]*rateVisitor),
+		maxEntries:      10000,
+		cleanupInterval: time.Minute,
+		lastCleanup:     time.Now(),
+	}
+}
+
+func (l *rateLimiter) visitor(key string, now time.Time) *rate.Limiter {
+	v, ok := l.visitors[key]
+	if !ok {
+		v = &rateVisitor{limiter: rate.NewLimiter(l.policy.limit, l.policy.burst)}
+		l.visitors[key] = v
+	}
+	v.lastSeen = now
+
+	if len(l.visitors) > l.maxEntries || now.Sub(l.lastCleanup) >= l.cleanupInterval {
+		// バケットが満タンに戻るまで放置された訪問者は破棄しても挙動が変わらない
+		refill := time.Duration(float64(l.policy.burst) / float64(l.policy.limit) * float64(time.Second))
+		for k, entry := range l.visitors {
+			if now.Sub(entry.lastSeen) > refill {
+				delete(l.visitors, k)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	return v.limiter
+}
+
+// トークンを1つ消費し、不足していれば再試行までの待ち時間を返す
+func (l *rateLimiter) take(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reservation := l.visitor(key, now).ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Minute
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// トークンを消費せずに残量のみ確認する
+func (l *rateLimiter) peek(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v, ok := l.visitors[key]
+	if !ok {
+		return true, 0
+	}
+	tokens := v.limiter.TokensAt(now)
+	if tokens >= 1 {
+		return true, 0
+	}
+	return false, time.Duration((1 - tokens) / float64(l.policy.limit) * float64(time.Second))
+}
+
+func clientIP(c echo.Context) string {
+	ip := c.RealIP()
+	if ip == "" {
+		ip = "unknown"
+	}
+	return ip
+}
+
+func respondTooManyRequests(c echo.Context, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(seconds))
+	return newAPIError(http.StatusTooManyRequests, "too_many_requests", "too many requests")
+}
+
+func (pSrv *server) rateLimit(limiter *rateLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if allowed, retryAfter := limiter.take(clientIP(c)); !allowed {
+				return respondTooManyRequests(c, retryAfter)
+			}
+			return next(c)
+		}
+	}
+}
+
 func (pSrv *server) requireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		secret := c.Request().Header.Get("X-Admin-Secret")
-		if secret == "" || secret != pSrv.adminSecret {
-			return c.String(http.StatusForbidden, "admin authentication failed")
+		ip := clientIP(c)
+		if pSrv.adminAuthLimiter != nil {
+			if allowed, retryAfter := pSrv.adminAuthLimiter.peek(ip); !allowed {
+				return respondTooManyRequests(c, retryAfter)
+			}
+		}
+
+		admin, err := pSrv.verifyAdminToken(bearerToken(c))
+		if err != nil {
+			if pSrv.adminAuthLimiter != nil {
+				pSrv.adminAuthLimiter.take(ip)
+			}
+			return newAPIError(http.StatusForbidden, "admin_auth_failed", "admin authentication failed")
+		}
+		c.Set(adminContextKey, admin)
+		return pSrv.auditAdmin(next)(c)
+	}
+}
+
+// X-Forwarded-Forは信頼するプロキシ (Next.jsのサーバーやリバースプロキシ) から来たときだけ使う
+// 未指定ならループバック・リンクローカル・プライベートアドレスを信頼する
+func createIPExtractor(trustedProxies string) (echo.IPExtractor, error) {
+	if strings.TrimSpace(trustedProxies) == "" {
+		return echo.ExtractIPFromXFFHeader(), nil
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, rawProxy := range strings.Split(trustedProxies, ",") {
+		proxy := strings.TrimSpace(rawProxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
 		}
-		return next(c)
+		options = append(options, echo.TrustIPRange(ipRange))
 	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
 }
 
 func corsConfig(allowedOrigin string) middleware.CORSConfig {
 	cfg := middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{echo.GET, echo.POST, echo.PUT, echo.DELETE, echo.OPTIONS},
-		AllowHeaders: []string{"Content-Type", "Authorization"},
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{echo.GET, echo.HEAD, echo.POST, echo.PUT, echo.PATCH, echo.DELETE, echo.OPTIONS},
+		AllowHeaders:  []string{"Content-Type", "Authorization", "If-Match", "Upload-Length", "Upload-Offset", "Upload-Metadata"},
+		ExposeHeaders: []string{"ETag", echo.HeaderXRequestID, "Location", "Upload-Offset", "Upload-Length"},
 	}
 
 	if allowedOrigin != "" && allowedOrigin != "*" {
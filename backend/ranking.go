@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 急上昇モードでクリックの重みが半減するまでの時間
+const trendingHalfLife = 24 * time.Hour
+
+type rankingWorkHit struct {
+	WorkID     string
+	ClickCount int64
+}
+
+var rankingWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+	"all": 0,
+}
+
+func parseRankingWindow(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	window, ok := rankingWindows[raw]
+	if !ok {
+		return 0, errors.New("window must be one of 24h, 7d, 30d, all")
+	}
+	return window, nil
+}
+
+func (pSrv *server) rankWorkIDs(ctx context.Context, window time.Duration, trending bool, limit int) ([]rankingWorkHit, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	now := time.Now().UTC()
+
+	// 集計済みの日は正午のクリックとして扱うため、期間の境界に掛かる日は正午が期間内かで決まる
+	joinCondition := "c.work_id = w.id"
+	args := []interface{}{}
+	if window > 0 {
+		joinCondition += " AND c.clicked_at >= ?"
+		args = append(args, now.Add(-window))
+	}
+
+	scoreExpr := "COALESCE(SUM(c.click_count), 0)"
+	if trending {
+		scoreExpr = "COALESCE(SUM(c.click_count * POWER(0.5, GREATEST(EXTRACT(EPOCH FROM (?::timestamptz - c.clicked_at)), 0) / ?)), 0)"
+		args = append([]interface{}{now, trendingHalfLife.Seconds()}, args...)
+	}
+	args = append(args, workStatusPublished, limit)
+
+	type row struct {
+		WorkID     string `gorm:"column:work_id"`
+		ClickCount int64  `gorm:"column:click_count"`
+	}
+
+	var rows []row
+	err := pSrv.db.WithContext(ctx).Raw(
+		`
+		WITH daily_clicks AS (`+workDailyClicksSQL+`)
+		SELECT
+			w.id AS work_id,
+			COALESCE(SUM(c.click_count), 0) AS click_count,
+			`+scoreExpr+` AS score
+		FROM isirmt_works w
+		LEFT JOIN daily_clicks c ON `+joinCondition+`
+		WHERE w.deleted_at IS NULL
+			AND w.status = ?
+		GROUP BY w.id
+		ORDER BY score DESC, w.created_at DESC, w.id DESC
+		LIMIT ?
+		`,
+		args...,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]rankingWorkHit, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, rankingWorkHit{
+			WorkID:     row.WorkID,
+			ClickCount: row.ClickCount,
+		})
+	}
+
+	return hits, nil
+}
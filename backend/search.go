@@ -85,14 +85,14 @@ func (pSrv *server) embedQuery(ctx context.Context, queryText string) ([]float64
 func (pSrv *server) handleSearchWorks(c echo.Context) error {
 	queryText := strings.TrimSpace(c.QueryParam("q"))
 	if queryText == "" {
-		return c.String(http.StatusBadRequest, "query parameter 'q' is required")
+		return invalidParameter("q", "query parameter 'q' is required")
 	}
 
 	limit := 10
 	if rawLimit := strings.TrimSpace(c.QueryParam("limit")); rawLimit != "" {
 		parsedLimit, err := strconv.Atoi(rawLimit)
 		if err != nil {
-			return c.String(http.StatusBadRequest, "limit must be number")
+			return invalidParameter("limit", "limit must be number")
 		}
 		limit = parsedLimit
 	}
@@ -101,12 +101,12 @@ func (pSrv *server) handleSearchWorks(c echo.Context) error {
 
 	vector, err := pSrv.embedQuery(ctx, queryText)
 	if err != nil {
-		return c.String(http.StatusBadRequest, "failed to embed query")
+		return newAPIError(http.StatusServiceUnavailable, "embedding_unavailable", "failed to embed query").withCause(err)
 	}
 
 	hits, err := pSrv.searchWorkIDs(ctx, vector, limit)
 	if err != nil {
-		return c.String(http.StatusInternalServerError, "failed to search works")
+		return internalError("failed to search works", err)
 	}
 
 	if len(hits) == 0 {
@@ -119,10 +119,10 @@ func (pSrv *server) handleSearchWorks(c echo.Context) error {
 	}
 
 	works, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx)).
-		Where(pSrv.q.IsirmtWork.ID.In(workIDs...)).
+		Where(pSrv.q.IsirmtWork.ID.In(workIDs...), pSrv.q.IsirmtWork.Status.In(listedWorkStatuses...)).
 		Find()
 	if err != nil {
-		return c.String(http.StatusInternalServerError, "failed to fetch works")
+		return internalError("failed to fetch works", err)
 	}
 
 	workByID := make(map[string]*model.IsirmtWork, len(works))
@@ -169,16 +169,20 @@ func (pSrv *server) searchWorkIDs(ctx context.Context, vector []float64, limit i
 	err := pSrv.db.WithContext(ctx).Raw(
 		`
 		SELECT
-			work_id,
-			MIN(embedding <=> ?::vector) AS distance
-		FROM isirmt_work_search_chunks
-		WHERE embedding_model = ?
-		GROUP BY work_id
+			s.work_id,
+			MIN(s.embedding <=> ?::vector) AS distance
+		FROM isirmt_work_search_chunks s
+		JOIN isirmt_works w ON w.id = s.work_id
+		WHERE s.embedding_model = ?
+			AND w.deleted_at IS NULL
+			AND w.status = ?
+		GROUP BY s.work_id
 		ORDER BY distance ASC
 		LIMIT ?
 		`,
 		vectorText,
 		pSrv.searchEmbeddingModel,
+		workStatusPublished,
 		limit,
 	).Scan(&rows).Error
 	if err != nil {
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"realtime/internal/query"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -15,11 +16,17 @@ type server struct {
 	allowedOrigin        string
 	maxUploadSize        uint32
 	adminSecret          string
+	adminAllowedEmails   []string
 	embeddingBaseURL     string
 	searchEmbeddingModel string
 	httpClient           *http.Client
-	clickLimiter         *clickLimiter
+	clickLimiter         clickLimiter
+	adminAuthLimiter     *rateLimiter
 	wsHub                *wsHub
+	storage              imageStorage
+	imageURLTTL          time.Duration
+	imageTranscoder      *imageTranscoder
+	uploads              *resumableUploads
 	wsSeq                uint64
 }
 
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var errImageObjectNotFound = errors.New("image object not found")
+
+type imageObject struct {
+	body io.ReadCloser
+	size int64
+}
+
+// 画像ファイルの保存先。キーはuploadDirからの相対パスと同じ形 (例: derived/<id>_medium.webp)
+type imageStorage interface {
+	put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	open(ctx context.Context, key string) (*imageObject, error)
+	stat(ctx context.Context, key string) (int64, error)
+	remove(ctx context.Context, key string) error
+	walk(ctx context.Context, fn func(key string, size int64, modTime time.Time) error) error
+	// 直接取得できるURLを発行できない場合は空文字を返す
+	signedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+func createImageStorage(backend string, uploadDir string) (imageStorage, error) {
+	switch backend {
+	case "local":
+		return createLocalImageStorage(uploadDir), nil
+	case "s3":
+		return createS3ImageStorageFromEnv()
+	default:
+		return nil, errors.New("image storage must be local or s3. got " + backend)
+	}
+}
+
+func createImageStorageFromEnv(uploadDir string) imageStorage {
+	storage, err := createImageStorage(getEnv("IMAGE_STORAGE", "local"), uploadDir)
+	if err != nil {
+		log.Fatalf("failed to create image storage. %v", err)
+	}
+	return storage
+}
+
+func putImageFile(ctx context.Context, storage imageStorage, key string, path string, contentType string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return storage.put(ctx, key, file, info.Size(), contentType)
+}
+
+func downloadImageObject(ctx context.Context, storage imageStorage, key string, path string) error {
+	object, err := storage.open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer object.body.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, object.body); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+type localImageStorage struct {
+	dir string
+}
+
+func createLocalImageStorage(dir string) *localImageStorage {
+	return &localImageStorage{dir: dir}
+}
+
+func (s *localImageStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+func (s *localImageStorage) put(_ context.Context, key string, body io.Reader, _ int64, _ string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// 書き込み途中のファイルを配信しないよう、書き終えてから置き換える
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, body); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s *localImageStorage) open(_ context.Context, key string) (*imageObject, error) {
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errImageObjectNotFound
+		}
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &imageObject{body: file, size: info.Size()}, nil
+}
+
+func (s *localImageStorage) stat(_ context.Context, key string) (int64, error) {
+	info, err := os.Stat(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, errImageObjectNotFound
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *localImageStorage) remove(_ context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localImageStorage) walk(ctx context.Context, fn func(key string, size int64, modTime time.Time) error) error {
+	return filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// ドットで始まるディレクトリは再開可能アップロードの途中データなどで、画像ではない
+		if entry.IsDir() && path != s.dir && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info.Size(), info.ModTime())
+	})
+}
+
+func (s *localImageStorage) signedURL(context.Context, string, time.Duration) (string, error) {
+	return "", nil
+}
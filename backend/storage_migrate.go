@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"mime"
+	"path"
+	"time"
+)
+
+// 例: backend migrate-images -from local -to s3
+// 既に同じサイズのファイルがあればスキップするため、途中で止まっても再実行できる
+func runImageMigration(args []string) error {
+	flags := flag.NewFlagSet("migrate-images", flag.ContinueOnError)
+	from := flags.String("from", "local", "source storage (local or s3)")
+	to := flags.String("to", "s3", "destination storage (local or s3)")
+	dryRun := flags.Bool("dry-run", false, "only list files to copy")
+	overwrite := flags.Bool("overwrite", false, "copy even if the destination already has the file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == *to {
+		return errors.New("-from and -to must be different")
+	}
+
+	uploadDir := getEnv("UPLOAD_DIR", "./uploads")
+	source, err := createImageStorage(*from, uploadDir)
+	if err != nil {
+		return err
+	}
+	destination, err := createImageStorage(*to, uploadDir)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	copied, skipped, failed := 0, 0, 0
+	err = source.walk(ctx, func(key string, size int64, _ time.Time) error {
+		if !*overwrite {
+			if existing, err := destination.stat(ctx, key); err == nil && existing == size {
+				skipped++
+				return nil
+			}
+		}
+		if *dryRun {
+			log.Printf("[migrate images] would copy %s (%d bytes)", key, size)
+			copied++
+			return nil
+		}
+
+		if err := copyImageObject(ctx, source, destination, key); err != nil {
+			log.Printf("[migrate images] failed to copy %s: %v", key, err)
+			failed++
+			return nil
+		}
+		copied++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[migrate images] copied %d, skipped %d, failed %d", copied, skipped, failed)
+	if failed > 0 {
+		return errors.New("some files could not be copied")
+	}
+	return nil
+}
+
+func copyImageObject(ctx context.Context, source imageStorage, destination imageStorage, key string) error {
+	object, err := source.open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer object.body.Close()
+
+	return destination.put(ctx, key, object.body, object.size, mime.TypeByExtension(path.Ext(key)))
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	s3AmzDateFormat    = "20060102T150405Z"
+	s3MaxPresignTTL    = 7 * 24 * time.Hour
+)
+
+// S3互換ストレージ (AWS S3, MinIO, R2など)。署名はSignature Version 4で行う
+type s3ImageStorage struct {
+	endpoint        *url.URL
+	region          string
+	bucket          string
+	accessKeyID     string
+	secretAccessKey string
+	pathStyle       bool
+	client          *http.Client
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func createS3ImageStorageFromEnv() (*s3ImageStorage, error) {
+	region := getEnv("S3_REGION", "us-east-1")
+	bucket := os.Getenv("S3_BUCKET")
+	accessKeyID := os.Getenv("S3_ACCESS_KEY_ID")
+	secretAccessKey := os.Getenv("S3_SECRET_ACCESS_KEY")
+	if bucket == "" || accessKeyID == "" || secretAccessKey == "" {
+		return nil, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required for s3 storage")
+	}
+
+	endpoint, err := url.Parse(getEnv("S3_ENDPOINT", "https://s3."+region+".amazonaws.com"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, errors.New("S3_ENDPOINT must be absolute url")
+	}
+
+	return &s3ImageStorage{
+		endpoint:        endpoint,
+		region:          region,
+		bucket:          bucket,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		pathStyle:       getEnv("S3_FORCE_PATH_STYLE", "false") == "true",
+		client:          &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *s3ImageStorage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	basePath := strings.TrimRight(u.Path, "/")
+	if s.pathStyle {
+		u.Path = basePath + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+	}
+	u.RawPath = s3Escape(u.Path, true)
+	u.RawQuery = ""
+	return &u
+}
+
+func (s *s3ImageStorage) do(ctx context.Context, method string, target *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	if body != nil && size == 0 {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.sign(req, time.Now().UTC())
+
+	return s.client.Do(req)
+}
+
+func s3ResponseError(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("s3 returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+}
+
+func (s *s3ImageStorage) put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	res, err := s.do(ctx, http.MethodPut, s.objectURL(key), body, size, header)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s3ResponseError(res)
+	}
+	return nil
+}
+
+func (s *s3ImageStorage) open(ctx context.Context, key string) (*imageObject, error) {
+	res, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return &imageObject{body: res.Body, size: res.ContentLength}, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, errImageObjectNotFound
+	default:
+		defer res.Body.Close()
+		return nil, s3ResponseError(res)
+	}
+}
+
+func (s *s3ImageStorage) stat(ctx context.Context, key string) (int64, error) {
+	res, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.ContentLength, nil
+	case http.StatusNotFound:
+		return 0, errImageObjectNotFound
+	default:
+		return 0, fmt.Errorf("s3 returned %s", res.Status)
+	}
+}
+
+func (s *s3ImageStorage) remove(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s3ResponseError(res)
+	}
+	return nil
+}
+
+func (s *s3ImageStorage) walk(ctx context.Context, fn func(key string, size int64, modTime time.Time) error) error {
+	token := ""
+	for {
+		target := s.objectURL("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		target.RawQuery = s3CanonicalQuery(query)
+
+		res, err := s.do(ctx, http.MethodGet, target, nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode != http.StatusOK {
+			err := s3ResponseError(res)
+			res.Body.Close()
+			return err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			if err := fn(object.Key, object.Size, object.LastModified); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// 署名付きURL (クエリ文字列に署名を含める形式) を発行する
+func (s *s3ImageStorage) signedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > s3MaxPresignTTL {
+		return "", errors.New("presign ttl must be between 1s and 7 days")
+	}
+	return s.presign(key, ttl, time.Now().UTC()), nil
+}
+
+func (s *s3ImageStorage) presign(key string, ttl time.Duration, now time.Time) string {
+	target := s.objectURL(key)
+	scope := s.credentialScope(now)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3SigningAlgorithm)
+	query.Set("X-Amz-Credential", s.accessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(s3AmzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	target.RawQuery = s3CanonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		s3CanonicalURI(target.EscapedPath()),
+		target.RawQuery,
+		"host:" + target.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	signature := s.signature(now, scope, canonicalRequest)
+	target.RawQuery += "&X-Amz-Signature=" + signature
+	return target.String()
+}
+
+func (s *s3ImageStorage) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format(s3AmzDateFormat)
+	scope := s.credentialScope(now)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": s3UnsignedPayload,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3CanonicalURI(req.URL.EscapedPath()),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm,
+		s.accessKeyID,
+		scope,
+		signedHeaders,
+		s.signature(now, scope, canonicalRequest),
+	))
+}
+
+func (s *s3ImageStorage) credentialScope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+func (s *s3ImageStorage) signature(now time.Time, scope string, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		now.Format(s3AmzDateFormat),
+		scope,
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// RFC 3986の非予約文字以外をすべてエンコードする (SigV4の規則)
+func s3Escape(value string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func s3CanonicalURI(escapedPath string) string {
+	if escapedPath == "" {
+		return "/"
+	}
+	return escapedPath
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
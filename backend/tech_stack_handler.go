@@ -18,7 +18,7 @@ func (pSrv *server) handleGetTechStacks(c echo.Context) error {
 	ctx := c.Request().Context()
 	stacks, err := pSrv.q.CommonTechStack.WithContext(ctx).Order(pSrv.q.CommonTechStack.Name).Find()
 	if err != nil {
-		return c.String(500, "failed to fetch tech stacks")
+		return internalError("failed to fetch tech stacks", err)
 	}
 
 	return c.JSON(200, stacks)
@@ -27,16 +27,16 @@ func (pSrv *server) handleGetTechStacks(c echo.Context) error {
 func (pSrv *server) handleGetTechStack(c echo.Context) error {
 	stackID := c.Param("id")
 	if stackID == "" {
-		return c.String(400, "tech stack id is required")
+		return invalidParameter("id", "tech stack id is required")
 	}
 
 	ctx := c.Request().Context()
 	stack, err := pSrv.q.CommonTechStack.WithContext(ctx).Where(pSrv.q.CommonTechStack.ID.Eq(stackID)).First()
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.String(404, "tech stack not found")
+			return notFound("tech_stack_not_found", "tech stack not found")
 		}
-		return c.String(500, "failed to fetch tech stack")
+		return internalError("failed to fetch tech stack", err)
 	}
 
 	return c.JSON(200, stack)
@@ -45,12 +45,13 @@ func (pSrv *server) handleGetTechStack(c echo.Context) error {
 func (pSrv *server) handleCreateTechStack(c echo.Context) error {
 	var req createTechStackRequest
 	if err := c.Bind(&req); err != nil {
-		return c.String(400, "invalid request body")
+		return invalidRequestBody()
 	}
 
 	name := strings.TrimSpace(req.Name)
 	if name == "" {
-		return c.String(400, "name is required")
+		return newAPIError(http.StatusBadRequest, "validation_failed", "name is required").
+			withDetails(validationErrorResponse{Errors: []fieldError{{Field: "name", Code: validationCodeRequired}}})
 	}
 
 	ctx := c.Request().Context()
@@ -60,8 +61,11 @@ func (pSrv *server) handleCreateTechStack(c echo.Context) error {
 	}
 
 	if err := pSrv.q.CommonTechStack.WithContext(ctx).Create(newStack); err != nil {
-		return c.String(500, "failed to create tech stack")
+		return internalError("failed to create tech stack", err)
 	}
 
+	if newStack.ID != nil {
+		setAuditTargetID(c, *newStack.ID)
+	}
 	return c.JSON(http.StatusCreated, newStack)
 }
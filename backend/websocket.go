@@ -19,7 +19,7 @@ const (
 	wsBufferSize = 32
 )
 
-type workClickEvent struct {
+type workEvent struct {
 	Type   string `json:"type"`
 	WorkID string `json:"workId"`
 	Seq    uint64 `json:"seq"`
@@ -85,7 +85,7 @@ func (h *wsHub) Broadcast(message []byte) {
 
 func (pSrv *server) handleWS(c echo.Context) error {
 	if pSrv.wsHub == nil {
-		return c.NoContent(http.StatusServiceUnavailable)
+		return newAPIError(http.StatusServiceUnavailable, "realtime_unavailable", "realtime updates are unavailable")
 	}
 
 	upgrader := pSrv.wsUpgrader()
@@ -155,12 +155,20 @@ func (pSrv *server) wsUpgrader() websocket.Upgrader {
 }
 
 func (pSrv *server) broadcastWorkClick(workID string) {
+	pSrv.broadcastWorkEvent("work_click", workID)
+}
+
+func (pSrv *server) broadcastWorkPublished(workID string) {
+	pSrv.broadcastWorkEvent("work_published", workID)
+}
+
+func (pSrv *server) broadcastWorkEvent(eventType string, workID string) {
 	if pSrv.wsHub == nil {
 		return
 	}
 	seq := atomic.AddUint64(&pSrv.wsSeq, 1)
-	event := workClickEvent{
-		Type:   "work_click",
+	event := workEvent{
+		Type:   eventType,
 		WorkID: workID,
 		Seq:    seq,
 	}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"realtime/internal/query"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const workPatchMaxBodyLength = 1 << 20 // 1 MiB
+
+var errWorkVersionConflict = errors.New("work was modified by another request")
+
+type workRelationsPatch struct {
+	imageIDs     *[]string
+	techStackIDs *[]string
+	urls         *[]createWorkURL
+}
+
+func workETag(version int32) string {
+	return `"` + strconv.Itoa(int(version)) + `"`
+}
+
+// If-Matchが指定されていれば期待するバージョンを返す
+func parseIfMatchVersion(c echo.Context) (*int32, error) {
+	raw := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(raw, `"`) || !strings.HasSuffix(raw, `"`) || len(raw) < 2 {
+		return nil, invalidParameter("If-Match", "If-Match must be a strong ETag")
+	}
+	version, err := strconv.ParseInt(raw[1:len(raw)-1], 10, 32)
+	if err != nil {
+		return nil, invalidParameter("If-Match", "If-Match must be a strong ETag")
+	}
+	expected := int32(version)
+	return &expected, nil
+}
+
+func workVersionConflict() *apiError {
+	return newAPIError(http.StatusPreconditionFailed, "work_version_conflict", errWorkVersionConflict.Error()).
+		withCause(errWorkVersionConflict)
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+func replaceWorkRelationSets(ctx context.Context, tx *query.Query, workID string, patch workRelationsPatch) error {
+	if patch.imageIDs != nil {
+		if _, err := tx.IsirmtWorkImage.WithContext(ctx).Where(tx.IsirmtWorkImage.WorkID.Eq(workID)).Delete(); err != nil {
+			return err
+		}
+	}
+	if patch.urls != nil {
+		if _, err := tx.IsirmtWorkURL.WithContext(ctx).Where(tx.IsirmtWorkURL.WorkID.Eq(workID)).Delete(); err != nil {
+			return err
+		}
+	}
+	if patch.techStackIDs != nil {
+		if _, err := tx.IsirmtWorkTechStack.WithContext(ctx).Where(tx.IsirmtWorkTechStack.WorkID.Eq(workID)).Delete(); err != nil {
+			return err
+		}
+	}
+
+	relations := workRelations{}
+	if patch.imageIDs != nil {
+		relations.imageIDs = *patch.imageIDs
+	}
+	if patch.urls != nil {
+		relations.urls = *patch.urls
+	}
+	if patch.techStackIDs != nil {
+		relations.techStackIDs = *patch.techStackIDs
+	}
+	return createWorkRelations(ctx, tx, workID, relations)
+}
+
+// JSON Merge Patch (RFC 7396) として送られた項目のみ更新する
+func (pSrv *server) handlePatchWork(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	expectedVersion, err := parseIfMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, workPatchMaxBodyLength+1))
+	if err != nil {
+		return invalidRequestBody()
+	}
+	if len(body) > workPatchMaxBodyLength {
+		return newAPIError(http.StatusRequestEntityTooLarge, "request_too_large", "request body is too large")
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return newAPIError(http.StatusBadRequest, "invalid_request_body", "request body must be JSON object")
+	}
+
+	ctx := c.Request().Context()
+
+	before, err := pSrv.fetchWorkSnapshot(ctx, workID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("work_not_found", "work not found")
+		}
+		return internalError("failed to fetch work", err)
+	}
+	setAuditBefore(c, before)
+
+	if expectedVersion != nil && *expectedVersion != before.Version {
+		c.Response().Header().Set("ETag", workETag(before.Version))
+		return workVersionConflict()
+	}
+	if len(patch) == 0 {
+		c.Response().Header().Set("ETag", workETag(before.Version))
+		return c.JSON(http.StatusOK, before)
+	}
+
+	v := &workValidator{}
+	updates := map[string]interface{}{}
+	relationsPatch := workRelationsPatch{}
+	searchDirty := false
+	slugPatched := false
+	var slug *string
+	var thumbnailID string
+	var status *string
+	var publishAt *string
+	publishAtSet := false
+
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		raw := patch[key]
+		switch key {
+		case "slug":
+			if value, ok := v.patchNullableString(raw, key); ok {
+				slug = v.slug(value)
+				slugPatched = true
+				updates["slug"] = slug
+			}
+		case "title", "comment":
+			if value, ok := v.patchString(raw, key); ok {
+				updates[key] = v.requiredText(key, value)
+				searchDirty = true
+			}
+		case "description":
+			if value, ok := v.patchNullableString(raw, key); ok {
+				var description *string
+				if value != nil {
+					description = normalizeOptionalText(*value)
+				}
+				updates["description"] = description
+				searchDirty = true
+			}
+		case "accent_color":
+			if value, ok := v.patchString(raw, key); ok {
+				updates["accent_color"] = v.accentColor(value)
+			}
+		case "published_date":
+			if value, ok := v.patchString(raw, key); ok {
+				updates["created_at"] = v.publishedDate(value)
+			}
+		case "thumbnail_image_id":
+			if value, ok := v.patchString(raw, key); ok {
+				thumbnailID = v.requiredText(key, value)
+				updates["thumbnail_image_id"] = thumbnailID
+			}
+		case "work_image_ids":
+			if values, ok := v.patchStrings(raw, key); ok {
+				imageIDs := v.workImageIDs(values)
+				relationsPatch.imageIDs = &imageIDs
+			}
+		case "tech_stack_ids":
+			if values, ok := v.patchStrings(raw, key); ok {
+				techStackIDs := v.techStackIDs(values)
+				relationsPatch.techStackIDs = &techStackIDs
+				searchDirty = true
+			}
+		case "urls":
+			entries := []createWorkURL{}
+			if !isJSONNull(raw) && json.Unmarshal(raw, &entries) != nil {
+				v.add(key, validationCodeInvalidType)
+				continue
+			}
+			urls := v.urls(entries)
+			relationsPatch.urls = &urls
+		case "status":
+			if value, ok := v.patchString(raw, key); ok {
+				status = &value
+			}
+		case "publish_at":
+			if value, ok := v.patchNullableString(raw, key); ok {
+				publishAt = value
+				publishAtSet = true
+			}
+		default:
+			v.add(key, validationCodeUnknownField)
+		}
+	}
+
+	publication := v.publication(status, publishAt, publishAtSet, before, time.Now())
+
+	if slugPatched {
+		if err := pSrv.checkWorkSlug(ctx, v, slug, workID); err != nil {
+			return internalError("failed to validate work", err)
+		}
+	}
+	var imageIDs []string
+	if relationsPatch.imageIDs != nil {
+		imageIDs = *relationsPatch.imageIDs
+	}
+	if err := pSrv.checkWorkImages(ctx, v, thumbnailID, imageIDs); err != nil {
+		return internalError("failed to validate work", err)
+	}
+	if relationsPatch.techStackIDs != nil {
+		if err := pSrv.checkWorkTechStacks(ctx, v, *relationsPatch.techStackIDs); err != nil {
+			return internalError("failed to validate work", err)
+		}
+	}
+	if !v.valid() {
+		return respondValidationErrors(c, v)
+	}
+
+	if publication != nil {
+		updates["status"] = publication.status
+		updates["publish_at"] = publication.publishAt
+	}
+	if searchDirty {
+		updates["search_dirty"] = true
+		updates["search_index_error"] = nil
+	}
+	updates["version"] = gorm.Expr("version + 1")
+
+	if err := pSrv.q.Transaction(func(tx *query.Query) error {
+		if err := pSrv.recordWorkRevisionBaseline(ctx, tx, workID); err != nil {
+			return err
+		}
+		if err := updateWorkWithVersion(ctx, tx, workID, expectedVersion, updates); err != nil {
+			return err
+		}
+		if err := replaceWorkRelationSets(ctx, tx, workID, relationsPatch); err != nil {
+			return err
+		}
+
+		return pSrv.recordWorkRevision(ctx, tx, workID, adminEmail(c))
+	}); err != nil {
+		if errors.Is(err, errWorkVersionConflict) {
+			return workVersionConflict()
+		}
+		return internalError("failed to update work", err)
+	}
+
+	after, err := pSrv.fetchWorkSnapshot(ctx, workID)
+	if err != nil {
+		return internalError("failed to fetch work", err)
+	}
+	setAuditAfter(c, after)
+	if publication != nil && publication.status == workStatusPublished && before.Status != workStatusPublished {
+		pSrv.broadcastWorkPublished(workID)
+	}
+
+	c.Response().Header().Set("ETag", workETag(after.Version))
+	return c.JSON(http.StatusOK, after)
+}
+
+// 期待するバージョンが指定されていれば、一致する場合のみ更新する
+func updateWorkWithVersion(ctx context.Context, tx *query.Query, workID string, expectedVersion *int32, updates map[string]interface{}) error {
+	workQuery := tx.IsirmtWork.WithContext(ctx).Where(tx.IsirmtWork.ID.Eq(workID))
+	if expectedVersion != nil {
+		workQuery = workQuery.Where(tx.IsirmtWork.Version.Eq(*expectedVersion))
+	}
+	result, err := workQuery.Updates(updates)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected == 0 {
+		return errWorkVersionConflict
+	}
+	return nil
+}
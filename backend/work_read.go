@@ -1,116 +1,449 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"realtime/internal/query"
 	"realtime/internal/query/model"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
 )
 
 type workResponse struct {
 	ID               string                   `json:"id"`
+	Slug             *string                  `json:"slug"`
 	Title            string                   `json:"title"`
 	Comment          string                   `json:"comment"`
 	CreatedAt        string                   `json:"created_at"`
+	Status           string                   `json:"status"`
+	PublishAt        *string                  `json:"publish_at"`
+	UpdatedAt        string                   `json:"updated_at"`
+	Version          int32                    `json:"version"`
 	AccentColor      string                   `json:"accent_color"`
 	Description      *string                  `json:"description"`
 	ThumbnailImageID *string                  `json:"thumbnail_image_id"`
-	Images           []*model.IsirmtWorkImage `json:"images"`
+	Images           []workImageResponse      `json:"images"`
 	Urls             []*model.IsirmtWorkURL   `json:"urls"`
 	TechStacks       []*model.CommonTechStack `json:"tech_stacks"`
+	ClickCount       *int64                   `json:"click_count,omitempty"`
+	DeletedAt        *string                  `json:"deleted_at,omitempty"`
+}
+
+type workImageResponse struct {
+	ID           *string `json:"id"`
+	ImageID      string  `json:"image_id"`
+	DisplayOrder int32   `json:"display_order"`
+	AltText      *string `json:"alt_text"`
+}
+
+type workPageResponse struct {
+	Items      []workResponse `json:"items"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+type workListFilter struct {
+	techStackIDs  []string
+	publishedFrom *time.Time
+	publishedTo   *time.Time
+	accentColor   string
+	statuses      []string
 }
 
 func (pSrv *server) withWorkRelations(workQuery query.IIsirmtWorkDo) query.IIsirmtWorkDo {
 	return workQuery.Preload(
 		pSrv.q.IsirmtWork.WorkImages.Order(pSrv.q.IsirmtWorkImage.DisplayOrder),
+		pSrv.q.IsirmtWork.WorkImages.Image,
 		pSrv.q.IsirmtWork.URLs.Order(pSrv.q.IsirmtWorkURL.DisplayOrder),
 		pSrv.q.IsirmtWork.TechStacks,
 	)
 }
 
-func (pSrv *server) respondWorks(c echo.Context, works []*model.IsirmtWork) error {
+func buildWorkResponse(work *model.IsirmtWork) workResponse {
+	createdAt := ""
+	if work.CreatedAt != nil {
+		createdAt = work.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	accentColor := ""
+	if work.AccentColor != nil {
+		accentColor = *work.AccentColor
+	}
+
+	images := make([]workImageResponse, 0, len(work.WorkImages))
+	for _, workImage := range work.WorkImages {
+		image := workImageResponse{
+			ID:           workImage.ID,
+			ImageID:      workImage.ImageID,
+			DisplayOrder: workImage.DisplayOrder,
+		}
+		if workImage.Image != nil {
+			image.AltText = workImage.Image.AltText
+		}
+		images = append(images, image)
+	}
+
+	urls := work.URLs
+	if urls == nil {
+		urls = []*model.IsirmtWorkURL{}
+	}
+
+	techStacks := work.TechStacks
+	if techStacks == nil {
+		techStacks = []*model.CommonTechStack{}
+	}
+
+	updatedAt := ""
+	if work.UpdatedAt != nil {
+		updatedAt = work.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	var publishAt *string
+	if work.PublishAt != nil {
+		formatted := work.PublishAt.UTC().Format(time.RFC3339Nano)
+		publishAt = &formatted
+	}
+
+	var deletedAt *string
+	if work.DeletedAt.Valid {
+		formatted := work.DeletedAt.Time.UTC().Format(time.RFC3339Nano)
+		deletedAt = &formatted
+	}
+
+	return workResponse{
+		ID:               *work.ID,
+		Slug:             work.Slug,
+		Title:            work.Title,
+		Comment:          work.Comment,
+		CreatedAt:        createdAt,
+		Status:           work.Status,
+		PublishAt:        publishAt,
+		UpdatedAt:        updatedAt,
+		Version:          work.Version,
+		AccentColor:      accentColor,
+		Description:      work.Description,
+		ThumbnailImageID: work.ThumbnailImageID,
+		Images:           images,
+		Urls:             urls,
+		TechStacks:       techStacks,
+		DeletedAt:        deletedAt,
+	}
+}
+
+func buildWorkResponses(works []*model.IsirmtWork) []workResponse {
 	responses := make([]workResponse, 0, len(works))
 	for _, work := range works {
 		if work.ID == nil {
 			continue
 		}
+		responses = append(responses, buildWorkResponse(work))
+	}
+
+	return responses
+}
+
+func (pSrv *server) respondWorks(c echo.Context, works []*model.IsirmtWork) error {
+	return c.JSON(http.StatusOK, buildWorkResponses(works))
+}
 
-		createdAt := ""
-		if work.CreatedAt != nil {
-			createdAt = work.CreatedAt.UTC().Format(time.RFC3339Nano)
+func (pSrv *server) respondWorkPage(c echo.Context, works []*model.IsirmtWork, nextCursor *string) error {
+	return c.JSON(http.StatusOK, workPageResponse{
+		Items:      buildWorkResponses(works),
+		NextCursor: nextCursor,
+	})
+}
+
+func parseWorkListFilter(c echo.Context) (*workListFilter, error) {
+	filter := &workListFilter{}
+
+	techSet := map[string]struct{}{}
+	for _, rawIDs := range c.QueryParams()["tech_stack_id"] {
+		for _, id := range strings.Split(rawIDs, ",") {
+			trimmed := strings.TrimSpace(id)
+			if trimmed == "" {
+				continue
+			}
+			if _, exists := techSet[trimmed]; exists {
+				continue
+			}
+			techSet[trimmed] = struct{}{}
+			filter.techStackIDs = append(filter.techStackIDs, trimmed)
 		}
+	}
 
-		workID := *work.ID
-		images := work.WorkImages
-		if images == nil {
-			images = []*model.IsirmtWorkImage{}
+	if from := strings.TrimSpace(c.QueryParam("published_from")); from != "" {
+		parsed, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return nil, invalidParameter("published_from", "published_from must be formatted as YYYY-MM-DD")
 		}
+		parsed = parsed.UTC()
+		filter.publishedFrom = &parsed
+	}
 
-		urls := work.URLs
-		if urls == nil {
-			urls = []*model.IsirmtWorkURL{}
+	if to := strings.TrimSpace(c.QueryParam("published_to")); to != "" {
+		parsed, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, invalidParameter("published_to", "published_to must be formatted as YYYY-MM-DD")
 		}
+		// 指定日を含めるため翌日0時未満で絞り込む
+		parsed = parsed.UTC().AddDate(0, 0, 1)
+		filter.publishedTo = &parsed
+	}
 
-		techStacks := work.TechStacks
-		if techStacks == nil {
-			techStacks = []*model.CommonTechStack{}
+	if accentColor := strings.TrimSpace(c.QueryParam("accent_color")); accentColor != "" {
+		if !strings.HasPrefix(accentColor, "#") {
+			accentColor = "#" + accentColor
+		}
+		accentColor = strings.ToLower(accentColor)
+		if !hexColorPattern.MatchString(accentColor) {
+			return nil, invalidParameter("accent_color", "accent_color must be formatted as #rrggbb")
 		}
+		filter.accentColor = accentColor
+	}
+
+	return filter, nil
+}
 
-		responses = append(responses, workResponse{
-			ID:               workID,
-			Title:            work.Title,
-			Comment:          work.Comment,
-			CreatedAt:        createdAt,
-			AccentColor:      *work.AccentColor,
-			Description:      work.Description,
-			ThumbnailImageID: work.ThumbnailImageID,
-			Images:           images,
-			Urls:             urls,
-			TechStacks:       techStacks,
-		})
+func (pSrv *server) applyWorkListFilter(ctx context.Context, workQuery query.IIsirmtWorkDo, filter *workListFilter) query.IIsirmtWorkDo {
+	if len(filter.techStackIDs) > 0 {
+		workTechStack := pSrv.q.IsirmtWorkTechStack
+		matchedWorkIDs := workTechStack.WithContext(ctx).
+			Select(workTechStack.WorkID).
+			Where(workTechStack.TechStackID.In(filter.techStackIDs...)).
+			Group(workTechStack.WorkID).
+			Having(workTechStack.TechStackID.Count().Eq(len(filter.techStackIDs)))
+		workQuery = workQuery.Where(pSrv.q.IsirmtWork.Columns(pSrv.q.IsirmtWork.ID).In(matchedWorkIDs))
+	}
+	if filter.publishedFrom != nil {
+		workQuery = workQuery.Where(pSrv.q.IsirmtWork.CreatedAt.Gte(*filter.publishedFrom))
+	}
+	if filter.publishedTo != nil {
+		workQuery = workQuery.Where(pSrv.q.IsirmtWork.CreatedAt.Lt(*filter.publishedTo))
+	}
+	if filter.accentColor != "" {
+		workQuery = workQuery.Where(pSrv.q.IsirmtWork.AccentColor.Eq(filter.accentColor))
+	}
+	if len(filter.statuses) > 0 {
+		workQuery = workQuery.Where(pSrv.q.IsirmtWork.Status.In(filter.statuses...))
 	}
 
-	return c.JSON(http.StatusOK, responses)
+	return workQuery
 }
 
 func (pSrv *server) handleGetWorks(c echo.Context) error {
+	filter, err := parseWorkListFilter(c)
+	if err != nil {
+		return err
+	}
+	filter.statuses = listedWorkStatuses
+
+	return pSrv.listWorks(c, filter)
+}
+
+// 管理者向けに下書きや予約中を含めて一覧する
+func (pSrv *server) handleGetPreviewWorks(c echo.Context) error {
+	filter, err := parseWorkListFilter(c)
+	if err != nil {
+		return err
+	}
+	statuses, err := parseWorkStatuses(c.QueryParams()["status"])
+	if err != nil {
+		return invalidParameter("status", err.Error())
+	}
+	filter.statuses = statuses
+
+	return pSrv.listWorks(c, filter)
+}
+
+func (pSrv *server) listWorks(c echo.Context, filter *workListFilter) error {
+	limit, err := parsePageLimit(c.QueryParam("limit"), 20, 100)
+	if err != nil {
+		return invalidParameter("limit", err.Error())
+	}
+
 	ctx := c.Request().Context()
+	workQuery := pSrv.applyWorkListFilter(ctx, pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx)), filter)
 
-	works, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx)).
-		Order(pSrv.q.IsirmtWork.CreatedAt.Desc()).
+	if rawCursor := strings.TrimSpace(c.QueryParam("cursor")); rawCursor != "" {
+		cursor, err := decodePageCursor(rawCursor)
+		if err != nil {
+			return invalidParameter("cursor", "cursor is invalid")
+		}
+		workQuery = workQuery.Where(field.Or(
+			pSrv.q.IsirmtWork.CreatedAt.Lt(cursor.At),
+			field.And(pSrv.q.IsirmtWork.CreatedAt.Eq(cursor.At), pSrv.q.IsirmtWork.ID.Lt(cursor.ID)),
+		))
+	}
+
+	works, err := workQuery.
+		Order(pSrv.q.IsirmtWork.CreatedAt.Desc(), pSrv.q.IsirmtWork.ID.Desc()).
+		Limit(limit + 1).
 		Find()
 	if err != nil {
-		return c.String(500, "failed to fetch works")
+		return internalError("failed to fetch works", err)
 	}
 
-	return pSrv.respondWorks(c, works)
+	var nextCursor *string
+	if len(works) > limit {
+		works = works[:limit]
+		last := works[len(works)-1]
+		if last.ID != nil && last.CreatedAt != nil {
+			cursor := encodePageCursor(*last.CreatedAt, *last.ID)
+			nextCursor = &cursor
+		}
+	}
+
+	return pSrv.respondWorkPage(c, works, nextCursor)
 }
 
-func (pSrv *server) handleGetRankingWorks(c echo.Context) error {
-	limit := 10
-	if rawLimit := strings.TrimSpace(c.QueryParam("limit")); rawLimit != "" {
-		parsedLimit, err := strconv.Atoi(rawLimit)
-		if err != nil {
-			return c.String(http.StatusBadRequest, "limit must be number")
+func (pSrv *server) fetchWorkSnapshot(ctx context.Context, workID string) (*workResponse, error) {
+	work, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx)).
+		Where(pSrv.q.IsirmtWork.ID.Eq(workID)).
+		First()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := buildWorkResponse(work)
+	return &snapshot, nil
+}
+
+// 作品IDはUUIDなので、形式の違うIDは存在しない作品として扱う
+func parseWorkIDParam(c echo.Context) (string, error) {
+	workID := strings.TrimSpace(c.Param("id"))
+	if workID == "" {
+		return "", invalidParameter("id", "work id is required")
+	}
+	if _, err := uuid.Parse(workID); err != nil {
+		return "", notFound("work_not_found", "work not found")
+	}
+	return workID, nil
+}
+
+func (pSrv *server) handleGetWork(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	work, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx)).
+		Where(pSrv.q.IsirmtWork.ID.Eq(workID), pSrv.q.IsirmtWork.Status.In(viewableWorkStatuses...)).
+		First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("work_not_found", "work not found")
 		}
-		limit = parsedLimit
+		return internalError("failed to fetch work", err)
+	}
+
+	c.Response().Header().Set("ETag", workETag(work.Version))
+	return c.JSON(http.StatusOK, buildWorkResponse(work))
+}
+
+func (pSrv *server) handleGetPreviewWork(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	work, err := pSrv.fetchWorkSnapshot(c.Request().Context(), workID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("work_not_found", "work not found")
+		}
+		return internalError("failed to fetch work", err)
+	}
+
+	c.Response().Header().Set("ETag", workETag(work.Version))
+	return c.JSON(http.StatusOK, work)
+}
+
+func (pSrv *server) handleGetWorkBySlug(c echo.Context) error {
+	slug := strings.ToLower(strings.TrimSpace(c.Param("slug")))
+	if slug == "" {
+		return invalidParameter("slug", "slug is required")
+	}
+
+	ctx := c.Request().Context()
+	work, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx)).
+		Where(pSrv.q.IsirmtWork.Slug.Eq(slug), pSrv.q.IsirmtWork.Status.In(viewableWorkStatuses...)).
+		First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("work_not_found", "work not found")
+		}
+		return internalError("failed to fetch work", err)
+	}
+
+	c.Response().Header().Set("ETag", workETag(work.Version))
+	return c.JSON(http.StatusOK, buildWorkResponse(work))
+}
+
+func (pSrv *server) handleGetRankingWorks(c echo.Context) error {
+	limit, err := parsePageLimit(c.QueryParam("limit"), 10, 100)
+	if err != nil {
+		return invalidParameter("limit", err.Error())
+	}
+
+	window, err := parseRankingWindow(c.QueryParam("window"))
+	if err != nil {
+		return invalidParameter("window", err.Error())
+	}
+
+	trending := false
+	switch mode := strings.TrimSpace(c.QueryParam("mode")); mode {
+	case "", "count":
+	case "trending":
+		trending = true
+	default:
+		return invalidParameter("mode", "mode must be count or trending")
 	}
 
 	ctx := c.Request().Context()
+	hits, err := pSrv.rankWorkIDs(ctx, window, trending, limit)
+	if err != nil {
+		return internalError("failed to fetch ranking works", err)
+	}
+
+	if len(hits) == 0 {
+		return c.JSON(http.StatusOK, []workResponse{})
+	}
+
+	workIDs := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		workIDs = append(workIDs, hit.WorkID)
+	}
+
 	works, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx)).
-		LeftJoin(pSrv.q.IsirmtWorkClick, pSrv.q.IsirmtWorkClick.WorkID.EqCol(pSrv.q.IsirmtWork.ID)).
-		Group(pSrv.q.IsirmtWork.ID).
-		Order(pSrv.q.IsirmtWorkClick.ID.Count().Desc()).
-		Order(pSrv.q.IsirmtWork.CreatedAt.Desc()).
-		Limit(limit).
+		Where(pSrv.q.IsirmtWork.ID.In(workIDs...), pSrv.q.IsirmtWork.Status.In(listedWorkStatuses...)).
 		Find()
 	if err != nil {
-		return c.String(500, "failed to fetch ranking works")
+		return internalError("failed to fetch ranking works", err)
+	}
+
+	workByID := make(map[string]*model.IsirmtWork, len(works))
+	for _, work := range works {
+		if work.ID != nil {
+			workByID[*work.ID] = work
+		}
+	}
+
+	responses := make([]workResponse, 0, len(hits))
+	for _, hit := range hits {
+		work := workByID[hit.WorkID]
+		if work == nil {
+			continue
+		}
+		clickCount := hit.ClickCount
+		response := buildWorkResponse(work)
+		response.ClickCount = &clickCount
+		responses = append(responses, response)
 	}
 
-	return pSrv.respondWorks(c, works)
+	return c.JSON(http.StatusOK, responses)
 }
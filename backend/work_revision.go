@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"realtime/internal/query"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type workRevisionSummary struct {
+	ID        string    `json:"id"`
+	Revision  int       `json:"revision"`
+	Actor     *string   `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type workRevisionEntry struct {
+	ID        string          `json:"id"`
+	Revision  int             `json:"revision"`
+	Actor     *string         `json:"actor"`
+	Snapshot  json.RawMessage `json:"snapshot"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type workRevisionChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type workRevisionDiffResponse struct {
+	From    int                  `json:"from"`
+	To      int                  `json:"to"`
+	Changes []workRevisionChange `json:"changes"`
+}
+
+type workRevisionField struct {
+	name  string
+	value interface{}
+}
+
+// 作品と関連データの現在の状態を履歴として保存する。書き込みと同じトランザクション内で呼ぶ
+func (pSrv *server) recordWorkRevision(ctx context.Context, tx *query.Query, workID string, actor string) error {
+	work, err := pSrv.withWorkRelations(tx.IsirmtWork.WithContext(ctx)).
+		Where(tx.IsirmtWork.ID.Eq(workID)).
+		First()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := json.Marshal(buildWorkResponse(work))
+	if err != nil {
+		return err
+	}
+
+	var actorPtr *string
+	if actor != "" {
+		actorPtr = &actor
+	}
+
+	return tx.IsirmtWork.WithContext(ctx).UnderlyingDB().Exec(
+		`
+		INSERT INTO isirmt_work_revisions (work_id, revision, actor, snapshot)
+		SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?::jsonb
+		FROM isirmt_work_revisions
+		WHERE work_id = ?
+		`,
+		workID,
+		actorPtr,
+		string(snapshot),
+		workID,
+	).Error
+}
+
+// 履歴導入前から存在する作品は、最初の編集前の状態を記録しておく
+func (pSrv *server) recordWorkRevisionBaseline(ctx context.Context, tx *query.Query, workID string) error {
+	var count int64
+	if err := tx.IsirmtWork.WithContext(ctx).UnderlyingDB().Raw(
+		`SELECT COUNT(*) FROM isirmt_work_revisions WHERE work_id = ?`,
+		workID,
+	).Scan(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return pSrv.recordWorkRevision(ctx, tx, workID, "")
+}
+
+func (pSrv *server) fetchWorkRevision(ctx context.Context, workID string, revision int) (*workRevisionEntry, error) {
+	var entries []workRevisionEntry
+	err := pSrv.db.WithContext(ctx).Raw(
+		`
+		SELECT id, revision, actor, snapshot, created_at
+		FROM isirmt_work_revisions
+		WHERE work_id = ? AND revision = ?
+		`,
+		workID,
+		revision,
+	).Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &entries[0], nil
+}
+
+func parseWorkRevision(raw string, name string) (int, error) {
+	revision, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || revision <= 0 {
+		return 0, invalidParameter(name, name+" must be positive number")
+	}
+	return revision, nil
+}
+
+// 差分比較用に、表示順や並びに左右されない形へ整える
+func workRevisionFields(snapshot workResponse) []workRevisionField {
+	imageIDs := make([]string, 0, len(snapshot.Images))
+	for _, image := range snapshot.Images {
+		imageIDs = append(imageIDs, image.ImageID)
+	}
+
+	urls := make([]createWorkURL, 0, len(snapshot.Urls))
+	for _, entry := range snapshot.Urls {
+		urls = append(urls, createWorkURL{
+			Label: entry.Label,
+			URL:   entry.URL,
+		})
+	}
+
+	techStackIDs := make([]string, 0, len(snapshot.TechStacks))
+	for _, techStack := range snapshot.TechStacks {
+		if techStack.ID != nil {
+			techStackIDs = append(techStackIDs, *techStack.ID)
+		}
+	}
+	sort.Strings(techStackIDs)
+
+	return []workRevisionField{
+		{name: "slug", value: snapshot.Slug},
+		{name: "title", value: snapshot.Title},
+		{name: "comment", value: snapshot.Comment},
+		{name: "description", value: snapshot.Description},
+		{name: "accent_color", value: snapshot.AccentColor},
+		{name: "thumbnail_image_id", value: snapshot.ThumbnailImageID},
+		{name: "created_at", value: snapshot.CreatedAt},
+		{name: "status", value: snapshot.Status},
+		{name: "publish_at", value: snapshot.PublishAt},
+		{name: "image_ids", value: imageIDs},
+		{name: "urls", value: urls},
+		{name: "tech_stack_ids", value: techStackIDs},
+	}
+}
+
+func diffWorkRevisions(before workResponse, after workResponse) []workRevisionChange {
+	beforeFields := workRevisionFields(before)
+	afterFields := workRevisionFields(after)
+
+	changes := make([]workRevisionChange, 0)
+	for index, field := range beforeFields {
+		if reflect.DeepEqual(field.value, afterFields[index].value) {
+			continue
+		}
+		changes = append(changes, workRevisionChange{
+			Field:  field.name,
+			Before: field.value,
+			After:  afterFields[index].value,
+		})
+	}
+	return changes
+}
+
+func (pSrv *server) handleGetWorkRevisions(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	var revisions []workRevisionSummary
+	err = pSrv.db.WithContext(c.Request().Context()).Raw(
+		`
+		SELECT id, revision, actor, created_at
+		FROM isirmt_work_revisions
+		WHERE work_id = ?
+		ORDER BY revision DESC
+		`,
+		workID,
+	).Scan(&revisions).Error
+	if err != nil {
+		return internalError("failed to fetch work revisions", err)
+	}
+	if revisions == nil {
+		revisions = []workRevisionSummary{}
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+func (pSrv *server) handleGetWorkRevision(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+	revision, err := parseWorkRevision(c.Param("revision"), "revision")
+	if err != nil {
+		return err
+	}
+
+	entry, err := pSrv.fetchWorkRevision(c.Request().Context(), workID, revision)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("revision_not_found", "revision not found")
+		}
+		return internalError("failed to fetch work revision", err)
+	}
+
+	return c.JSON(http.StatusOK, entry)
+}
+
+func (pSrv *server) handleDiffWorkRevisions(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+	from, err := parseWorkRevision(c.QueryParam("from"), "from")
+	if err != nil {
+		return err
+	}
+	to, err := parseWorkRevision(c.QueryParam("to"), "to")
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	snapshots := make([]workResponse, 0, 2)
+	for _, revision := range []int{from, to} {
+		entry, err := pSrv.fetchWorkRevision(ctx, workID, revision)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return notFound("revision_not_found", "revision not found")
+			}
+			return internalError("failed to fetch work revision", err)
+		}
+		var snapshot workResponse
+		if err := json.Unmarshal(entry.Snapshot, &snapshot); err != nil {
+			return internalError("failed to decode work revision", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return c.JSON(http.StatusOK, workRevisionDiffResponse{
+		From:    from,
+		To:      to,
+		Changes: diffWorkRevisions(snapshots[0], snapshots[1]),
+	})
+}
+
+// 内容のみを指定の版に戻す。公開状態は履歴に左右されないよう現在の値を維持する
+func (pSrv *server) handleRollbackWork(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+	revision, err := parseWorkRevision(c.Param("revision"), "revision")
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	before, err := pSrv.fetchWorkSnapshot(ctx, workID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("work_not_found", "work not found")
+		}
+		return internalError("failed to fetch work", err)
+	}
+	setAuditBefore(c, before)
+
+	entry, err := pSrv.fetchWorkRevision(ctx, workID, revision)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("revision_not_found", "revision not found")
+		}
+		return internalError("failed to fetch work revision", err)
+	}
+	var snapshot workResponse
+	if err := json.Unmarshal(entry.Snapshot, &snapshot); err != nil {
+		return internalError("failed to decode work revision", err)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, snapshot.CreatedAt)
+	if err != nil {
+		return internalError("failed to decode work revision", err)
+	}
+
+	if snapshot.Slug != nil {
+		if taken, err := pSrv.isWorkSlugTaken(ctx, *snapshot.Slug, workID); err != nil {
+			return internalError("failed to validate slug", err)
+		} else if taken {
+			return newAPIError(http.StatusConflict, "slug_taken", "slug is already in use")
+		}
+	}
+
+	relations := workRelations{
+		imageIDs:     make([]string, 0, len(snapshot.Images)),
+		techStackIDs: make([]string, 0, len(snapshot.TechStacks)),
+		urls:         make([]createWorkURL, 0, len(snapshot.Urls)),
+	}
+	for _, image := range snapshot.Images {
+		relations.imageIDs = append(relations.imageIDs, image.ImageID)
+	}
+	for _, entry := range snapshot.Urls {
+		relations.urls = append(relations.urls, createWorkURL{
+			Label: entry.Label,
+			URL:   entry.URL,
+		})
+	}
+	for _, techStack := range snapshot.TechStacks {
+		if techStack.ID != nil {
+			relations.techStackIDs = append(relations.techStackIDs, *techStack.ID)
+		}
+	}
+
+	// 履歴の作成後に削除された画像や技術スタックには戻せない
+	thumbnailImageID := ""
+	if snapshot.ThumbnailImageID != nil {
+		thumbnailImageID = *snapshot.ThumbnailImageID
+	}
+	v := &workValidator{}
+	if err := pSrv.checkWorkImages(ctx, v, thumbnailImageID, relations.imageIDs); err != nil {
+		return internalError("failed to validate images", err)
+	}
+	if err := pSrv.checkWorkTechStacks(ctx, v, relations.techStackIDs); err != nil {
+		return internalError("failed to validate tech stacks", err)
+	}
+	if !v.valid() {
+		return newAPIError(http.StatusConflict, "revision_reference_missing", "revision references deleted images or tech stacks").
+			withDetails(validationErrorResponse{Errors: v.errors})
+	}
+
+	var accentColor *string
+	if snapshot.AccentColor != "" {
+		accentColor = &snapshot.AccentColor
+	}
+
+	updates := map[string]interface{}{
+		"slug":               snapshot.Slug,
+		"title":              snapshot.Title,
+		"comment":            snapshot.Comment,
+		"accent_color":       accentColor,
+		"description":        snapshot.Description,
+		"thumbnail_image_id": snapshot.ThumbnailImageID,
+		"created_at":         createdAt.UTC(),
+		"search_dirty":       true,
+		"search_index_error": nil,
+		"version":            gorm.Expr("version + 1"),
+	}
+
+	if err := pSrv.q.Transaction(func(tx *query.Query) error {
+		if _, err := tx.IsirmtWork.WithContext(ctx).Where(tx.IsirmtWork.ID.Eq(workID)).Updates(updates); err != nil {
+			return err
+		}
+		if err := replaceWorkRelations(ctx, tx, workID, relations); err != nil {
+			return err
+		}
+
+		return pSrv.recordWorkRevision(ctx, tx, workID, adminEmail(c))
+	}); err != nil {
+		return internalError("failed to rollback work", err)
+	}
+
+	if after, err := pSrv.fetchWorkSnapshot(ctx, workID); err == nil {
+		setAuditAfter(c, after)
+	}
+
+	return c.String(http.StatusOK, "ok")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	workStatusDraft     = "draft"
+	workStatusPublished = "published"
+	workStatusScheduled = "scheduled"
+	workStatusUnlisted  = "unlisted"
+)
+
+var (
+	// 一覧・ランキング・検索に出す作品
+	listedWorkStatuses = []string{workStatusPublished}
+	// URLを知っていれば閲覧できる作品
+	viewableWorkStatuses = []string{workStatusPublished, workStatusUnlisted}
+)
+
+type workPublication struct {
+	status    string
+	publishAt *time.Time
+}
+
+func isWorkStatus(status string) bool {
+	switch status {
+	case workStatusDraft, workStatusPublished, workStatusScheduled, workStatusUnlisted:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseWorkStatuses(rawValues []string) ([]string, error) {
+	statuses := make([]string, 0)
+	statusSet := map[string]struct{}{}
+	for _, rawStatuses := range rawValues {
+		for _, status := range strings.Split(rawStatuses, ",") {
+			trimmed := strings.ToLower(strings.TrimSpace(status))
+			if trimmed == "" {
+				continue
+			}
+			if !isWorkStatus(trimmed) {
+				return nil, errors.New("status must be one of draft, published, scheduled, unlisted")
+			}
+			if _, exists := statusSet[trimmed]; exists {
+				continue
+			}
+			statusSet[trimmed] = struct{}{}
+			statuses = append(statuses, trimmed)
+		}
+	}
+	return statuses, nil
+}
+
+// 公開状態と公開日時を決める。予約日時を過ぎていればその場で公開にする
+func resolveWorkPublication(rawStatus string, rawPublishAt *string, now time.Time) (*workPublication, error) {
+	status := strings.ToLower(strings.TrimSpace(rawStatus))
+	if status == "" {
+		status = workStatusPublished
+	}
+	if !isWorkStatus(status) {
+		return nil, &fieldError{Field: "status", Code: validationCodeInvalidValue}
+	}
+
+	var publishAt *time.Time
+	if rawPublishAt != nil && strings.TrimSpace(*rawPublishAt) != "" {
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(*rawPublishAt))
+		if err != nil {
+			return nil, &fieldError{Field: "publish_at", Code: validationCodeInvalidFormat}
+		}
+		parsed = parsed.UTC()
+		publishAt = &parsed
+	}
+
+	switch status {
+	case workStatusScheduled:
+		if publishAt == nil {
+			return nil, &fieldError{Field: "publish_at", Code: validationCodeRequired}
+		}
+		if !publishAt.After(now) {
+			status = workStatusPublished
+		}
+	case workStatusPublished:
+		if publishAt == nil || publishAt.After(now) {
+			publishedAt := now.UTC()
+			publishAt = &publishedAt
+		}
+	}
+
+	return &workPublication{
+		status:    status,
+		publishAt: publishAt,
+	}, nil
+}
+
+func (pSrv *server) startWorkPublisher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := pSrv.publishScheduledWorks(ctx); err != nil {
+				log.Printf("[work publisher] %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (pSrv *server) publishScheduledWorks(ctx context.Context) error {
+	type row struct {
+		ID string `gorm:"column:id"`
+	}
+
+	var rows []row
+	err := pSrv.db.WithContext(ctx).Raw(
+		`
+		UPDATE isirmt_works
+		SET status = ?, version = version + 1, updated_at = NOW()
+		WHERE status = ?
+			AND publish_at <= NOW()
+			AND deleted_at IS NULL
+		RETURNING id
+		`,
+		workStatusPublished,
+		workStatusScheduled,
+	).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		log.Printf("[work publisher] published %s", row.ID)
+		pSrv.broadcastWorkPublished(row.ID)
+	}
+
+	return nil
+}
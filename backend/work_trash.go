@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+func (pSrv *server) fetchTrashedWorkSnapshot(ctx context.Context, workID string) (*workResponse, error) {
+	work, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx).Unscoped()).
+		Where(pSrv.q.IsirmtWork.ID.Eq(workID), pSrv.q.IsirmtWork.DeletedAt.IsNotNull()).
+		First()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := buildWorkResponse(work)
+	return &snapshot, nil
+}
+
+func (pSrv *server) handleGetTrashedWorks(c echo.Context) error {
+	ctx := c.Request().Context()
+	works, err := pSrv.withWorkRelations(pSrv.q.IsirmtWork.WithContext(ctx).Unscoped()).
+		Where(pSrv.q.IsirmtWork.DeletedAt.IsNotNull()).
+		Order(pSrv.q.IsirmtWork.DeletedAt.Desc(), pSrv.q.IsirmtWork.ID.Desc()).
+		Find()
+	if err != nil {
+		return internalError("failed to fetch trashed works", err)
+	}
+
+	return pSrv.respondWorks(c, works)
+}
+
+func (pSrv *server) handleRestoreWork(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	before, err := pSrv.fetchTrashedWorkSnapshot(ctx, workID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("work_not_found", "work not found in trash")
+		}
+		return internalError("failed to fetch work", err)
+	}
+	setAuditBefore(c, before)
+
+	if _, err := pSrv.q.IsirmtWork.WithContext(ctx).Unscoped().
+		Where(pSrv.q.IsirmtWork.ID.Eq(workID)).
+		Update(pSrv.q.IsirmtWork.DeletedAt, nil); err != nil {
+		return internalError("failed to restore work", err)
+	}
+
+	if after, err := pSrv.fetchWorkSnapshot(ctx, workID); err == nil {
+		setAuditAfter(c, after)
+	}
+
+	return c.String(http.StatusOK, "ok")
+}
+
+// ゴミ箱にある作品のみ完全に削除する。関連データは外部キーのCASCADEで消える
+func (pSrv *server) handlePurgeWork(c echo.Context) error {
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	before, err := pSrv.fetchTrashedWorkSnapshot(ctx, workID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notFound("work_not_found", "work not found in trash")
+		}
+		return internalError("failed to fetch work", err)
+	}
+	setAuditBefore(c, before)
+
+	if _, err := pSrv.q.IsirmtWork.WithContext(ctx).Unscoped().
+		Where(pSrv.q.IsirmtWork.ID.Eq(workID), pSrv.q.IsirmtWork.DeletedAt.IsNotNull()).
+		Delete(); err != nil {
+		return internalError("failed to purge work", err)
+	}
+
+	return c.String(http.StatusOK, "ok")
+}
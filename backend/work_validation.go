@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	validationCodeRequired      = "required"
+	validationCodeInvalidFormat = "invalid_format"
+	validationCodeInvalidValue  = "invalid_value"
+	validationCodeInvalidType   = "invalid_type"
+	validationCodeNotFound      = "not_found"
+	validationCodeTaken         = "taken"
+	validationCodeUnknownField  = "unknown_field"
+)
+
+type fieldError struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+}
+
+func (e *fieldError) Error() string {
+	return e.Field + ": " + e.Code
+}
+
+type validationErrorResponse struct {
+	Errors []fieldError `json:"errors"`
+}
+
+// 最初のエラーで止めず、すべての項目の問題をまとめて返す
+type workValidator struct {
+	errors []fieldError
+}
+
+type validatedWork struct {
+	slug             *string
+	title            string
+	comment          string
+	description      *string
+	accentColor      string
+	publishedAt      time.Time
+	thumbnailImageID string
+	publication      *workPublication
+	relations        workRelations
+}
+
+func (v *workValidator) add(field string, code string) {
+	v.errors = append(v.errors, fieldError{Field: field, Code: code})
+}
+
+func (v *workValidator) addError(err error) {
+	if fieldErr, ok := err.(*fieldError); ok {
+		v.errors = append(v.errors, *fieldErr)
+	}
+}
+
+func (v *workValidator) valid() bool {
+	return len(v.errors) == 0
+}
+
+func respondValidationErrors(c echo.Context, v *workValidator) error {
+	return newAPIError(http.StatusBadRequest, "validation_failed", "request has invalid fields").
+		withDetails(validationErrorResponse{Errors: v.errors})
+}
+
+func normalizeOptionalText(raw string) *string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+func (v *workValidator) requiredText(field string, raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		v.add(field, validationCodeRequired)
+	}
+	return trimmed
+}
+
+func (v *workValidator) slug(raw *string) *string {
+	slug, err := normalizeWorkSlug(raw)
+	if err != nil {
+		v.add("slug", validationCodeInvalidFormat)
+		return nil
+	}
+	return slug
+}
+
+func (v *workValidator) accentColor(raw string) string {
+	accentColor := strings.ToLower(strings.TrimSpace(raw))
+	if accentColor == "" {
+		accentColor = "#000000"
+	}
+	if !hexColorPattern.MatchString(accentColor) {
+		v.add("accent_color", validationCodeInvalidFormat)
+	}
+	return accentColor
+}
+
+func (v *workValidator) publishedDate(raw string) time.Time {
+	published := strings.TrimSpace(raw)
+	if published == "" {
+		v.add("published_date", validationCodeRequired)
+		return time.Time{}
+	}
+	parsed, err := time.Parse("2006-01-02", published)
+	if err != nil {
+		v.add("published_date", validationCodeInvalidFormat)
+		return time.Time{}
+	}
+	return parsed.UTC()
+}
+
+func (v *workValidator) workImageIDs(rawIDs []string) []string {
+	imageIDs := make([]string, 0, len(rawIDs))
+	for _, id := range rawIDs {
+		trimmed := strings.TrimSpace(id)
+		if trimmed == "" {
+			continue
+		}
+		imageIDs = append(imageIDs, trimmed)
+	}
+	return imageIDs
+}
+
+func (v *workValidator) techStackIDs(rawIDs []string) []string {
+	techStackIDs := make([]string, 0, len(rawIDs))
+	techSet := map[string]struct{}{}
+	for _, id := range rawIDs {
+		trimmed := strings.TrimSpace(id)
+		if trimmed == "" {
+			continue
+		}
+		if _, exists := techSet[trimmed]; exists {
+			continue
+		}
+		techSet[trimmed] = struct{}{}
+		techStackIDs = append(techStackIDs, trimmed)
+	}
+	if len(techStackIDs) == 0 {
+		v.add("tech_stack_ids", validationCodeRequired)
+	}
+	return techStackIDs
+}
+
+func (v *workValidator) urls(entries []createWorkURL) []createWorkURL {
+	filteredUrls := make([]createWorkURL, 0, len(entries))
+	for index, entry := range entries {
+		label := strings.TrimSpace(entry.Label)
+		url := strings.TrimSpace(entry.URL)
+		if label == "" && url == "" {
+			continue
+		}
+		field := "urls[" + strconv.Itoa(index) + "]"
+		if label == "" {
+			v.add(field+".label", validationCodeRequired)
+		}
+		if url == "" {
+			v.add(field+".url", validationCodeRequired)
+		}
+		filteredUrls = append(filteredUrls, createWorkURL{
+			Label: label,
+			URL:   url,
+		})
+	}
+	return filteredUrls
+}
+
+// currentがnilなら新規作成として扱う。更新時に公開状態が省略された場合はnilを返す
+func (v *workValidator) publication(status *string, publishAt *string, publishAtSet bool, current *workResponse, now time.Time) *workPublication {
+	if current != nil && status == nil && !publishAtSet {
+		return nil
+	}
+
+	rawStatus := ""
+	if status != nil {
+		rawStatus = *status
+	} else if current != nil {
+		rawStatus = current.Status
+	}
+	rawPublishAt := publishAt
+	if !publishAtSet && current != nil && strings.EqualFold(strings.TrimSpace(rawStatus), current.Status) {
+		rawPublishAt = current.PublishAt
+	}
+
+	publication, err := resolveWorkPublication(rawStatus, rawPublishAt, now)
+	if err != nil {
+		v.addError(err)
+		return nil
+	}
+	return publication
+}
+
+func (v *workValidator) patchString(raw json.RawMessage, field string) (string, bool) {
+	if isJSONNull(raw) {
+		v.add(field, validationCodeRequired)
+		return "", false
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		v.add(field, validationCodeInvalidType)
+		return "", false
+	}
+	return value, true
+}
+
+func (v *workValidator) patchNullableString(raw json.RawMessage, field string) (*string, bool) {
+	if isJSONNull(raw) {
+		return nil, true
+	}
+	value, ok := v.patchString(raw, field)
+	if !ok {
+		return nil, false
+	}
+	return &value, true
+}
+
+func (v *workValidator) patchStrings(raw json.RawMessage, field string) ([]string, bool) {
+	if isJSONNull(raw) {
+		return []string{}, true
+	}
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		v.add(field, validationCodeInvalidType)
+		return nil, false
+	}
+	return values, true
+}
+
+func (pSrv *server) checkWorkSlug(ctx context.Context, v *workValidator, slug *string, workID string) error {
+	if slug == nil {
+		return nil
+	}
+	taken, err := pSrv.isWorkSlugTaken(ctx, *slug, workID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		v.add("slug", validationCodeTaken)
+	}
+	return nil
+}
+
+func (pSrv *server) checkWorkImages(ctx context.Context, v *workValidator, thumbnailID string, imageIDs []string) error {
+	ids := append([]string{}, imageIDs...)
+	if thumbnailID != "" {
+		ids = append(ids, thumbnailID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var existingIDs []string
+	if err := pSrv.q.CommonImage.WithContext(ctx).
+		Where(pSrv.q.CommonImage.ID.In(ids...)).
+		Pluck(pSrv.q.CommonImage.ID, &existingIDs); err != nil {
+		return err
+	}
+	existing := make(map[string]struct{}, len(existingIDs))
+	for _, id := range existingIDs {
+		existing[id] = struct{}{}
+	}
+
+	if thumbnailID != "" {
+		if _, ok := existing[thumbnailID]; !ok {
+			v.add("thumbnail_image_id", validationCodeNotFound)
+		}
+	}
+	for index, id := range imageIDs {
+		if _, ok := existing[id]; !ok {
+			v.add("work_image_ids["+strconv.Itoa(index)+"]", validationCodeNotFound)
+		}
+	}
+	return nil
+}
+
+func (pSrv *server) checkWorkTechStacks(ctx context.Context, v *workValidator, techStackIDs []string) error {
+	if len(techStackIDs) == 0 {
+		return nil
+	}
+
+	var existingIDs []string
+	if err := pSrv.q.CommonTechStack.WithContext(ctx).
+		Where(pSrv.q.CommonTechStack.ID.In(techStackIDs...)).
+		Pluck(pSrv.q.CommonTechStack.ID, &existingIDs); err != nil {
+		return err
+	}
+	existing := make(map[string]struct{}, len(existingIDs))
+	for _, id := range existingIDs {
+		existing[id] = struct{}{}
+	}
+
+	for index, id := range techStackIDs {
+		if _, ok := existing[id]; !ok {
+			v.add("tech_stack_ids["+strconv.Itoa(index)+"]", validationCodeNotFound)
+		}
+	}
+	return nil
+}
+
+// 作成・更新で共通の検証。currentには更新前の作品を渡す
+func (pSrv *server) validateWorkRequest(ctx context.Context, req createWorkRequest, workID string, current *workResponse) (*validatedWork, *workValidator, error) {
+	v := &workValidator{}
+
+	work := &validatedWork{
+		slug:             v.slug(req.Slug),
+		title:            v.requiredText("title", req.Title),
+		comment:          v.requiredText("comment", req.Comment),
+		description:      normalizeOptionalText(req.Description),
+		accentColor:      v.accentColor(req.AccentColor),
+		publishedAt:      v.publishedDate(req.PublishedDate),
+		thumbnailImageID: v.requiredText("thumbnail_image_id", req.ThumbnailImageID),
+		publication:      v.publication(req.Status, req.PublishAt, req.PublishAt != nil, current, time.Now()),
+		relations: workRelations{
+			imageIDs:     v.workImageIDs(req.WorkImageIDs),
+			techStackIDs: v.techStackIDs(req.TechStackIDs),
+			urls:         v.urls(req.Urls),
+		},
+	}
+
+	if err := pSrv.checkWorkSlug(ctx, v, work.slug, workID); err != nil {
+		return nil, nil, err
+	}
+	if err := pSrv.checkWorkImages(ctx, v, work.thumbnailImageID, work.relations.imageIDs); err != nil {
+		return nil, nil, err
+	}
+	if err := pSrv.checkWorkTechStacks(ctx, v, work.relations.techStackIDs); err != nil {
+		return nil, nil, err
+	}
+
+	return work, v, nil
+}
@@ -3,12 +3,12 @@ package main
 import (
 	"context"
 	"errors"
+	"log"
 	"net/http"
 	"realtime/internal/query"
 	"realtime/internal/query/model"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
@@ -20,11 +20,14 @@ type createWorkURL struct {
 }
 
 type createWorkRequest struct {
+	Slug             *string         `json:"slug"`
 	Title            string          `json:"title"`
 	Comment          string          `json:"comment"`
 	Description      string          `json:"description"`
 	AccentColor      string          `json:"accent_color"`
 	PublishedDate    string          `json:"published_date"`
+	Status           *string         `json:"status"`
+	PublishAt        *string         `json:"publish_at"`
 	ThumbnailImageID string          `json:"thumbnail_image_id"`
 	WorkImageIDs     []string        `json:"work_image_ids"`
 	TechStackIDs     []string        `json:"tech_stack_ids"`
@@ -39,6 +42,35 @@ type workRelations struct {
 
 var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
 
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+func normalizeWorkSlug(raw *string) (*string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	slug := strings.ToLower(strings.TrimSpace(*raw))
+	if slug == "" {
+		return nil, nil
+	}
+	if len(slug) > 100 || !slugPattern.MatchString(slug) {
+		return nil, errors.New("slug must contain only lowercase letters, digits and hyphens")
+	}
+	return &slug, nil
+}
+
+func (pSrv *server) isWorkSlugTaken(ctx context.Context, slug string, excludeWorkID string) (bool, error) {
+	// ゴミ箱の作品もユニーク制約の対象なので含めて確認する
+	slugQuery := pSrv.q.IsirmtWork.WithContext(ctx).Unscoped().Where(pSrv.q.IsirmtWork.Slug.Eq(slug))
+	if excludeWorkID != "" {
+		slugQuery = slugQuery.Where(pSrv.q.IsirmtWork.ID.Neq(excludeWorkID))
+	}
+	count, err := slugQuery.Count()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func createWorkRelations(ctx context.Context, tx *query.Query, workID string, relations workRelations) error {
 	if len(relations.imageIDs) > 0 {
 		images := make([]*model.IsirmtWorkImage, 0, len(relations.imageIDs))
@@ -98,25 +130,39 @@ func replaceWorkRelations(ctx context.Context, tx *query.Query, workID string, r
 }
 
 func (pSrv *server) handleCreateWorkClick(c echo.Context) error {
-	workID := strings.TrimSpace(c.Param("id"))
-	if workID == "" {
-		return c.String(400, "work id is required")
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
 	}
 
-	ip := c.RealIP()
-	if ip == "" {
-		ip = "unknown"
+	ctx := c.Request().Context()
+	// ゴミ箱・下書き・予約中の作品へのクリックは記録も通知もしない
+	visible, err := pSrv.q.IsirmtWork.WithContext(ctx).
+		Where(pSrv.q.IsirmtWork.ID.Eq(workID), pSrv.q.IsirmtWork.Status.In(viewableWorkStatuses...)).
+		Count()
+	if err != nil {
+		return internalError("failed to fetch work", err)
 	}
-	if !pSrv.clickLimiter.isAllowedClick(ip, workID) {
-		return c.NoContent(http.StatusAccepted)
+	if visible == 0 {
+		return notFound("work_not_found", "work not found")
+	}
+
+	ip := clientIP(c)
+	if pSrv.clickLimiter != nil {
+		allowed, err := pSrv.clickLimiter.isAllowedClick(ctx, ip, workID)
+		if err != nil {
+			// 制限の判定に失敗した場合はクリックの記録を優先する
+			log.Printf("[click limiter] %v", err)
+		} else if !allowed {
+			return c.NoContent(http.StatusAccepted)
+		}
 	}
 
-	ctx := c.Request().Context()
 	click := &model.IsirmtWorkClick{
 		WorkID: workID,
 	}
 	if err := pSrv.q.IsirmtWorkClick.WithContext(ctx).Create(click); err != nil {
-		return c.String(500, "failed to create work click")
+		return internalError("failed to create work click", err)
 	}
 
 	pSrv.broadcastWorkClick(workID)
@@ -126,133 +172,37 @@ func (pSrv *server) handleCreateWorkClick(c echo.Context) error {
 func (pSrv *server) handleCreateWork(c echo.Context) error {
 	var req createWorkRequest
 	if err := c.Bind(&req); err != nil {
-		return c.String(400, "invalid request body")
-	}
-
-	title := strings.TrimSpace(req.Title)
-	if title == "" {
-		return c.String(400, "title is required")
-	}
-
-	comment := strings.TrimSpace(req.Comment)
-	if comment == "" {
-		return c.String(400, "comment is required")
-	}
-
-	accentColor := strings.TrimSpace(req.AccentColor)
-	if accentColor == "" {
-		accentColor = "#000000"
-	}
-	accentColor = strings.ToLower(accentColor)
-	if !hexColorPattern.MatchString(accentColor) {
-		return c.String(400, "accent_color must be formatted as #rrggbb")
-	}
-
-	description := strings.TrimSpace(req.Description)
-	var descriptionPtr *string
-	if description != "" {
-		descriptionPtr = &description
-	}
-
-	published := strings.TrimSpace(req.PublishedDate)
-	if published == "" {
-		return c.String(400, "published_date is required")
-	}
-	parsedPublished, err := time.Parse("2006-01-02", published)
-	if err != nil {
-		return c.String(400, "published_date must be formatted as YYYY-MM-DD")
-	}
-	publishedTime := parsedPublished.UTC()
-
-	thumbnailID := strings.TrimSpace(req.ThumbnailImageID)
-	if thumbnailID == "" {
-		return c.String(400, "thumbnail_image_id is required")
+		return invalidRequestBody()
 	}
 
 	ctx := c.Request().Context()
 
-	workImageIDs := make([]string, 0, len(req.WorkImageIDs))
-	imageIDSet := map[string]struct{}{}
-	for _, id := range req.WorkImageIDs {
-		trimmed := strings.TrimSpace(id)
-		if trimmed == "" {
-			continue
-		}
-		workImageIDs = append(workImageIDs, trimmed)
-		imageIDSet[trimmed] = struct{}{}
-	}
-	imageIDSet[thumbnailID] = struct{}{}
-
-	if len(imageIDSet) > 0 {
-		imageIDs := make([]string, 0, len(imageIDSet))
-		for id := range imageIDSet {
-			imageIDs = append(imageIDs, id)
-		}
-		count, err := pSrv.q.CommonImage.WithContext(ctx).Where(pSrv.q.CommonImage.ID.In(imageIDs...)).Count()
-		if err != nil {
-			return c.String(500, "failed to validate images")
-		}
-		if int(count) != len(imageIDs) {
-			return c.String(400, "unknown image id provided")
-		}
-	}
-
-	techStackIDs := make([]string, 0, len(req.TechStackIDs))
-	techSet := map[string]struct{}{}
-	for _, id := range req.TechStackIDs {
-		trimmed := strings.TrimSpace(id)
-		if trimmed == "" {
-			continue
-		}
-		if _, exists := techSet[trimmed]; exists {
-			continue
-		}
-		techSet[trimmed] = struct{}{}
-		techStackIDs = append(techStackIDs, trimmed)
-	}
-	if len(techStackIDs) == 0 {
-		return c.String(400, "tech_stack_ids is required")
-	}
-	if count, err := pSrv.q.CommonTechStack.WithContext(ctx).Where(pSrv.q.CommonTechStack.ID.In(techStackIDs...)).Count(); err != nil {
-		return c.String(500, "failed to validate tech stacks")
-	} else if int(count) != len(techStackIDs) {
-		return c.String(400, "unknown tech stack id provided")
+	validated, v, err := pSrv.validateWorkRequest(ctx, req, "", nil)
+	if err != nil {
+		return internalError("failed to validate work", err)
 	}
-
-	filteredUrls := make([]createWorkURL, 0, len(req.Urls))
-	for _, entry := range req.Urls {
-		label := strings.TrimSpace(entry.Label)
-		url := strings.TrimSpace(entry.URL)
-		if label == "" && url == "" {
-			continue
-		}
-		if label == "" || url == "" {
-			return c.String(400, "url entries require both label and url")
-		}
-		filteredUrls = append(filteredUrls, createWorkURL{
-			Label: label,
-			URL:   url,
-		})
+	if !v.valid() {
+		return respondValidationErrors(c, v)
 	}
 
-	thumbnailCopy := thumbnailID
-	accentCopy := accentColor
+	thumbnailCopy := validated.thumbnailImageID
+	accentCopy := validated.accentColor
+	publishedTime := validated.publishedAt
 	searchDirty := true
 
 	work := &model.IsirmtWork{
-		Title:            title,
-		Comment:          comment,
+		Slug:             validated.slug,
+		Title:            validated.title,
+		Comment:          validated.comment,
 		AccentColor:      &accentCopy,
-		Description:      descriptionPtr,
+		Description:      validated.description,
 		ThumbnailImageID: &thumbnailCopy,
 		CreatedAt:        &publishedTime,
 		SearchDirty:      &searchDirty,
+		Status:           validated.publication.status,
+		PublishAt:        validated.publication.publishAt,
 	}
-	relations := workRelations{
-		imageIDs:     workImageIDs,
-		techStackIDs: techStackIDs,
-		urls:         filteredUrls,
-	}
+	relations := validated.relations
 
 	if err := pSrv.q.Transaction(func(tx *query.Query) error {
 		if err := tx.IsirmtWork.WithContext(ctx).Create(work); err != nil {
@@ -263,178 +213,132 @@ func (pSrv *server) handleCreateWork(c echo.Context) error {
 		}
 		workID := *work.ID
 
-		return createWorkRelations(ctx, tx, workID, relations)
+		if err := createWorkRelations(ctx, tx, workID, relations); err != nil {
+			return err
+		}
+
+		return pSrv.recordWorkRevision(ctx, tx, workID, adminEmail(c))
 	}); err != nil {
-		return c.String(500, "failed to create work")
+		return internalError("failed to create work", err)
 	}
 
+	setAuditTargetID(c, *work.ID)
+	if work.Status == workStatusPublished {
+		pSrv.broadcastWorkPublished(*work.ID)
+	}
 	return c.JSON(http.StatusCreated, work)
 }
 
 func (pSrv *server) handleUpdateWork(c echo.Context) error {
-	workID := strings.TrimSpace(c.Param("id"))
-	if workID == "" {
-		return c.String(400, "work id is required")
-	}
-
-	var req createWorkRequest
-	if err := c.Bind(&req); err != nil {
-		return c.String(400, "invalid request body")
-	}
-
-	title := strings.TrimSpace(req.Title)
-	if title == "" {
-		return c.String(400, "title is required")
-	}
-
-	comment := strings.TrimSpace(req.Comment)
-	if comment == "" {
-		return c.String(400, "comment is required")
-	}
-
-	accentColor := strings.TrimSpace(req.AccentColor)
-	if accentColor == "" {
-		accentColor = "#000000"
-	}
-	accentColor = strings.ToLower(accentColor)
-	if !hexColorPattern.MatchString(accentColor) {
-		return c.String(400, "accent_color must be formatted as #rrggbb")
-	}
-
-	description := strings.TrimSpace(req.Description)
-	var descriptionPtr *string
-	if description != "" {
-		descriptionPtr = &description
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
 	}
 
-	published := strings.TrimSpace(req.PublishedDate)
-	if published == "" {
-		return c.String(400, "published_date is required")
-	}
-	parsedPublished, err := time.Parse("2006-01-02", published)
+	expectedVersion, err := parseIfMatchVersion(c)
 	if err != nil {
-		return c.String(400, "published_date must be formatted as YYYY-MM-DD")
+		return err
 	}
-	publishedTime := parsedPublished.UTC()
 
-	thumbnailID := strings.TrimSpace(req.ThumbnailImageID)
-	if thumbnailID == "" {
-		return c.String(400, "thumbnail_image_id is required")
+	var req createWorkRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidRequestBody()
 	}
 
 	ctx := c.Request().Context()
 
-	if _, err := pSrv.q.IsirmtWork.WithContext(ctx).Where(pSrv.q.IsirmtWork.ID.Eq(workID)).First(); err != nil {
+	before, err := pSrv.fetchWorkSnapshot(ctx, workID)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.String(404, "work not found")
+			return notFound("work_not_found", "work not found")
 		}
-		return c.String(500, "failed to fetch work")
+		return internalError("failed to fetch work", err)
 	}
+	setAuditBefore(c, before)
 
-	workImageIDs := make([]string, 0, len(req.WorkImageIDs))
-	imageIDSet := map[string]struct{}{}
-	for _, id := range req.WorkImageIDs {
-		trimmed := strings.TrimSpace(id)
-		if trimmed == "" {
-			continue
-		}
-		workImageIDs = append(workImageIDs, trimmed)
-		imageIDSet[trimmed] = struct{}{}
+	if expectedVersion != nil && *expectedVersion != before.Version {
+		c.Response().Header().Set("ETag", workETag(before.Version))
+		return workVersionConflict()
 	}
-	imageIDSet[thumbnailID] = struct{}{}
 
-	if len(imageIDSet) > 0 {
-		imageIDs := make([]string, 0, len(imageIDSet))
-		for id := range imageIDSet {
-			imageIDs = append(imageIDs, id)
-		}
-		count, err := pSrv.q.CommonImage.WithContext(ctx).Where(pSrv.q.CommonImage.ID.In(imageIDs...)).Count()
-		if err != nil {
-			return c.String(500, "failed to validate images")
-		}
-		if int(count) != len(imageIDs) {
-			return c.String(400, "unknown image id provided")
-		}
+	validated, v, err := pSrv.validateWorkRequest(ctx, req, workID, before)
+	if err != nil {
+		return internalError("failed to validate work", err)
+	}
+	if !v.valid() {
+		return respondValidationErrors(c, v)
 	}
+	publication := validated.publication
+	relations := validated.relations
 
-	techStackIDs := make([]string, 0, len(req.TechStackIDs))
-	techSet := map[string]struct{}{}
-	for _, id := range req.TechStackIDs {
-		trimmed := strings.TrimSpace(id)
-		if trimmed == "" {
-			continue
-		}
-		if _, exists := techSet[trimmed]; exists {
-			continue
-		}
-		techSet[trimmed] = struct{}{}
-		techStackIDs = append(techStackIDs, trimmed)
+	updates := map[string]interface{}{
+		"title":              validated.title,
+		"comment":            validated.comment,
+		"accent_color":       validated.accentColor,
+		"description":        validated.description,
+		"thumbnail_image_id": validated.thumbnailImageID,
+		"created_at":         validated.publishedAt,
+		"search_dirty":       true,
+		"search_index_error": nil,
+		"version":            gorm.Expr("version + 1"),
 	}
-	if len(techStackIDs) == 0 {
-		return c.String(400, "tech_stack_ids is required")
+	// slugが省略された場合は既存の値を維持する
+	if req.Slug != nil {
+		updates["slug"] = validated.slug
 	}
-	if count, err := pSrv.q.CommonTechStack.WithContext(ctx).Where(pSrv.q.CommonTechStack.ID.In(techStackIDs...)).Count(); err != nil {
-		return c.String(500, "failed to validate tech stacks")
-	} else if int(count) != len(techStackIDs) {
-		return c.String(400, "unknown tech stack id provided")
+	// 公開状態が省略された場合は既存の値を維持する
+	if publication != nil {
+		updates["status"] = publication.status
+		updates["publish_at"] = publication.publishAt
 	}
 
-	filteredUrls := make([]createWorkURL, 0, len(req.Urls))
-	for _, entry := range req.Urls {
-		label := strings.TrimSpace(entry.Label)
-		url := strings.TrimSpace(entry.URL)
-		if label == "" && url == "" {
-			continue
+	if err := pSrv.q.Transaction(func(tx *query.Query) error {
+		if err := pSrv.recordWorkRevisionBaseline(ctx, tx, workID); err != nil {
+			return err
 		}
-		if label == "" || url == "" {
-			return c.String(400, "url entries require both label and url")
+		if err := updateWorkWithVersion(ctx, tx, workID, expectedVersion, updates); err != nil {
+			return err
 		}
-		filteredUrls = append(filteredUrls, createWorkURL{
-			Label: label,
-			URL:   url,
-		})
-	}
-	relations := workRelations{
-		imageIDs:     workImageIDs,
-		techStackIDs: techStackIDs,
-		urls:         filteredUrls,
-	}
-
-	if err := pSrv.q.Transaction(func(tx *query.Query) error {
-		if _, err := tx.IsirmtWork.WithContext(ctx).Where(tx.IsirmtWork.ID.Eq(workID)).Updates(map[string]interface{}{
-			"title":              title,
-			"comment":            comment,
-			"accent_color":       accentColor,
-			"description":        descriptionPtr,
-			"thumbnail_image_id": thumbnailID,
-			"created_at":         publishedTime,
-			"search_dirty":       true,
-			"search_index_error": nil,
-		}); err != nil {
+		if err := replaceWorkRelations(ctx, tx, workID, relations); err != nil {
 			return err
 		}
 
-		return replaceWorkRelations(ctx, tx, workID, relations)
+		return pSrv.recordWorkRevision(ctx, tx, workID, adminEmail(c))
 	}); err != nil {
-		return c.String(500, "failed to update work")
+		if errors.Is(err, errWorkVersionConflict) {
+			return workVersionConflict()
+		}
+		return internalError("failed to update work", err)
 	}
 
-	return c.String(http.StatusOK, "ok")
+	after, err := pSrv.fetchWorkSnapshot(ctx, workID)
+	if err != nil {
+		return internalError("failed to fetch work", err)
+	}
+	setAuditAfter(c, after)
+	if publication != nil && publication.status == workStatusPublished && before.Status != workStatusPublished {
+		pSrv.broadcastWorkPublished(workID)
+	}
+
+	c.Response().Header().Set("ETag", workETag(after.Version))
+	return c.JSON(http.StatusOK, after)
 }
 
 func (pSrv *server) handleDeleteWork(c echo.Context) error {
-	workID := strings.TrimSpace(c.Param("id"))
-	if workID == "" {
-		return c.String(400, "work id is required")
+	workID, err := parseWorkIDParam(c)
+	if err != nil {
+		return err
 	}
 
 	ctx := c.Request().Context()
-	if _, err := pSrv.q.IsirmtWork.WithContext(ctx).Where(pSrv.q.IsirmtWork.ID.Eq(workID)).First(); err != nil {
+	before, err := pSrv.fetchWorkSnapshot(ctx, workID)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return c.String(404, "work not found")
+			return notFound("work_not_found", "work not found")
 		}
-		return c.String(500, "failed to fetch work")
+		return internalError("failed to fetch work", err)
 	}
+	setAuditBefore(c, before)
 
 	if err := pSrv.q.Transaction(func(tx *query.Query) error {
 		if _, err := tx.IsirmtWork.WithContext(ctx).Where(tx.IsirmtWork.ID.Eq(workID)).Delete(); err != nil {
@@ -442,7 +346,7 @@ func (pSrv *server) handleDeleteWork(c echo.Context) error {
 		}
 		return nil
 	}); err != nil {
-		return c.String(500, "failed to delete work")
+		return internalError("failed to delete work", err)
 	}
 
 	return c.String(http.StatusOK, "ok")
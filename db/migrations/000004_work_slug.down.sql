@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS uq_isirmt_works_slug;
+
+ALTER TABLE isirmt_works
+DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,5 @@
+/* 作品の公開用スラッグ */
+ALTER TABLE isirmt_works
+ADD COLUMN IF NOT EXISTS slug TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_isirmt_works_slug ON isirmt_works (slug);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS isirmt_work_click_rollup_state;
+DROP TABLE IF EXISTS isirmt_work_click_daily;
@@ -0,0 +1,18 @@
+/* 作品の日次クリック数集計 */
+CREATE TABLE
+    IF NOT EXISTS isirmt_work_click_daily (
+        work_id UUID NOT NULL REFERENCES isirmt_works (id) ON DELETE CASCADE,
+        day DATE NOT NULL,
+        click_count BIGINT NOT NULL DEFAULT 0,
+        PRIMARY KEY (work_id, day)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_isirmt_work_click_daily_day ON isirmt_work_click_daily (day);
+
+/* 日次集計の進捗 (rolled_until より前の日は集計済み) */
+CREATE TABLE
+    IF NOT EXISTS isirmt_work_click_rollup_state (
+        id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+        rolled_until DATE NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW ()
+    );
@@ -0,0 +1,8 @@
+/* クリック連打防止用の直近クリック時刻 (再起動・複数インスタンス間で共有) */
+CREATE UNLOGGED TABLE
+    IF NOT EXISTS isirmt_work_click_limits (
+        limit_key TEXT PRIMARY KEY,
+        last_clicked_at TIMESTAMPTZ NOT NULL DEFAULT NOW ()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_isirmt_work_click_limits_last_clicked_at ON isirmt_work_click_limits (last_clicked_at);
@@ -0,0 +1,20 @@
+/* 管理者による変更操作の監査ログ */
+CREATE TABLE
+    IF NOT EXISTS admin_audit_log (
+        id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+        actor TEXT NOT NULL,
+        method TEXT NOT NULL,
+        route TEXT NOT NULL,
+        target_id TEXT,
+        request_summary JSONB,
+        status INT NOT NULL,
+        before_snapshot JSONB,
+        after_snapshot JSONB,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW ()
+    );
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at ON admin_audit_log (created_at, id);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_actor ON admin_audit_log (actor);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_target_id ON admin_audit_log (target_id);
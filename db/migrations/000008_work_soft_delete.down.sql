@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_isirmt_works_deleted_at;
+
+ALTER TABLE isirmt_works
+DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+/* 作品の論理削除 (ゴミ箱) */
+ALTER TABLE isirmt_works
+ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_isirmt_works_deleted_at ON isirmt_works (deleted_at);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_isirmt_works_status_publish_at;
+
+ALTER TABLE isirmt_works
+DROP CONSTRAINT IF EXISTS chk_isirmt_works_status;
+
+ALTER TABLE isirmt_works
+DROP COLUMN IF EXISTS publish_at,
+DROP COLUMN IF EXISTS status;
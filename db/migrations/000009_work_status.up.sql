@@ -0,0 +1,12 @@
+/* 作品の公開状態 (draft / published / scheduled / unlisted) と公開日時 */
+ALTER TABLE isirmt_works
+ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published',
+ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+
+ALTER TABLE isirmt_works
+ADD CONSTRAINT chk_isirmt_works_status CHECK (
+    status IN ('draft', 'published', 'scheduled', 'unlisted')
+);
+
+/* 予約公開の対象を探すためのインデックス */
+CREATE INDEX IF NOT EXISTS idx_isirmt_works_status_publish_at ON isirmt_works (status, publish_at);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS isirmt_work_revisions;
@@ -0,0 +1,11 @@
+/* 作品の編集履歴 (作品本体と画像・URL・技術スタックのスナップショット) */
+CREATE TABLE
+    IF NOT EXISTS isirmt_work_revisions (
+        id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+        work_id UUID NOT NULL REFERENCES isirmt_works (id) ON DELETE CASCADE,
+        revision INT NOT NULL,
+        actor TEXT,
+        snapshot JSONB NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW (),
+        UNIQUE (work_id, revision)
+    );
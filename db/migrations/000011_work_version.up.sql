@@ -0,0 +1,4 @@
+/* 作品の更新日時と楽観的排他制御用のバージョン */
+ALTER TABLE isirmt_works
+ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW (),
+ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
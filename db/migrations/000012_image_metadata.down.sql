@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS common_image_variants;
+
+ALTER TABLE common_images
+DROP COLUMN IF EXISTS dominant_color,
+DROP COLUMN IF EXISTS height,
+DROP COLUMN IF EXISTS width;
@@ -0,0 +1,18 @@
+/* 画像の寸法と代表色 (プレースホルダ表示用) */
+ALTER TABLE common_images
+ADD COLUMN IF NOT EXISTS width INT,
+ADD COLUMN IF NOT EXISTS height INT,
+ADD COLUMN IF NOT EXISTS dominant_color TEXT;
+
+/* 配信用に縮小した画像 */
+CREATE TABLE
+    IF NOT EXISTS common_image_variants (
+        image_id UUID NOT NULL REFERENCES common_images (id) ON DELETE CASCADE,
+        variant TEXT NOT NULL,
+        width INT NOT NULL,
+        height INT NOT NULL,
+        file_path TEXT NOT NULL,
+        mime_type TEXT NOT NULL,
+        file_size BIGINT NOT NULL,
+        PRIMARY KEY (image_id, variant)
+    );
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS uq_common_images_content_hash;
+
+ALTER TABLE common_images
+DROP COLUMN IF EXISTS content_hash;
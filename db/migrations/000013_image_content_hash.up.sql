@@ -0,0 +1,5 @@
+/* 画像内容のSHA-256 (重複アップロードの検出とETagに使う) */
+ALTER TABLE common_images
+ADD COLUMN IF NOT EXISTS content_hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_common_images_content_hash ON common_images (content_hash);
@@ -0,0 +1,4 @@
+ALTER TABLE common_images
+DROP COLUMN IF EXISTS alt_text,
+DROP COLUMN IF EXISTS caption,
+DROP COLUMN IF EXISTS credit;
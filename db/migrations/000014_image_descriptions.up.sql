@@ -0,0 +1,5 @@
+/* 代替テキスト・キャプション・クレジット表記 */
+ALTER TABLE common_images
+ADD COLUMN IF NOT EXISTS alt_text TEXT,
+ADD COLUMN IF NOT EXISTS caption TEXT,
+ADD COLUMN IF NOT EXISTS credit TEXT;
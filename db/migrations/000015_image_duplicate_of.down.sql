@@ -0,0 +1,2 @@
+ALTER TABLE common_images
+DROP COLUMN IF EXISTS duplicate_of_image_id;
@@ -0,0 +1,3 @@
+/* 既存画像と同じ内容だったためハッシュを付けられなかった画像の参照先 (起動時のハッシュ補完で再処理しないために使う) */
+ALTER TABLE common_images
+ADD COLUMN IF NOT EXISTS duplicate_of_image_id UUID REFERENCES common_images (id) ON DELETE SET NULL;
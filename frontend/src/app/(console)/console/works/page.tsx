@@ -7,8 +7,8 @@ import { WorksProvider } from "@/contexts/worksContext";
 export default async function ImagesConsolePage() {
   return (
     <main className="relative w-full space-y-8 px-2.5 py-8 lg:px-16">
-      <ImagesProvider>
-        <WorksProvider>
+      <ImagesProvider source="admin">
+        <WorksProvider source="admin">
           <TechsProvider>
             <WorkRegisterForm />
             <WorksViewer />
@@ -1,10 +1,16 @@
 import { isAllowedEmail } from "@/lib/auth/allowedEmails";
+import { signAdminToken } from "@/lib/auth/adminToken";
 import { auth } from "@/lib/auth/options";
+import { forwardedForHeader } from "@/lib/forwardedFor";
 
-const ADMIN_HEADER = "X-Admin-Secret";
 const ADMIN_SECRET = process.env.ADMIN_SECRET;
 const BACKEND_BASE_URL = process.env.BACKEND_BASE_URL;
-const ADMIN_API_PATH_PREFIXES = ["/images", "/works", "/tech-stacks"];
+const ADMIN_API_PATH_PREFIXES = [
+  "/images",
+  "/works",
+  "/tech-stacks",
+  "/admin",
+];
 
 function isAllowedAdminApiPath(apiUrl: string) {
   return ADMIN_API_PATH_PREFIXES.some(
@@ -20,15 +26,23 @@ async function proxy(request: Request) {
   if (!session) return new Response("Unauthorized", { status: 401 });
   if (!apiUrl)
     return new Response("parameter(api_url) is required", { status: 400 });
-  const pathSegments = apiUrl.split("/");
+  const queryIndex = apiUrl.indexOf("?");
+  const apiPath = queryIndex >= 0 ? apiUrl.slice(0, queryIndex) : apiUrl;
+  const apiQuery = queryIndex >= 0 ? apiUrl.slice(queryIndex + 1) : "";
+  const pathSegments = apiPath.split("/");
   if (
-    !isAllowedAdminApiPath(apiUrl) ||
+    !isAllowedAdminApiPath(apiPath) ||
     pathSegments.some((segment) => segment === "." || segment === "..")
   )
     return new Response("parameter(api_url) is not allowed", {
       status: 400,
     });
-  if (!isAllowedEmail(session.user?.email) || session.user?.role !== "admin")
+  const adminEmail = session.user?.email;
+  if (
+    !adminEmail ||
+    !isAllowedEmail(adminEmail) ||
+    session.user?.role !== "admin"
+  )
     return new Response("Forbidden", { status: 403 });
 
   if (!ADMIN_SECRET) {
@@ -42,12 +56,19 @@ async function proxy(request: Request) {
     .map((segment) => encodeURIComponent(segment))
     .join("/");
   const upstreamUrl = new URL(safeApiUrl, BACKEND_BASE_URL);
+  upstreamUrl.search = new URLSearchParams(apiQuery).toString();
 
   const bodyAllowed = request.method !== "GET" && request.method !== "HEAD";
   const upstreamRequest = request.clone();
   const headers = new Headers(upstreamRequest.headers);
-  headers.set(ADMIN_HEADER, ADMIN_SECRET); // secretをヘッダーに追加
+  // 管理者のメールアドレスを含む短命トークンを付与
+  headers.set(
+    "Authorization",
+    `Bearer ${signAdminToken(adminEmail, ADMIN_SECRET)}`,
+  );
   headers.delete("host");
+  const forwardedFor = forwardedForHeader(request);
+  if (forwardedFor) headers.set("X-Forwarded-For", forwardedFor);
 
   const fetchInit: RequestInit & { duplex?: "half" } = {
     method: upstreamRequest.method,
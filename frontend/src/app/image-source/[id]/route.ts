@@ -1,3 +1,5 @@
+import { forwardedForHeader } from "@/lib/forwardedFor";
+
 type ImageSourceRouteContext = {
   params: Promise<{ id: string }>;
 };
@@ -17,8 +19,18 @@ export async function GET(
     backendBaseUrl,
   );
 
+  // 形式の選択 (WebP/AVIF) と304のため、ブラウザのヘッダーを引き継ぐ
+  const headers = new Headers();
+  for (const name of ["accept", "if-none-match"]) {
+    const value = request.headers.get(name);
+    if (value) headers.set(name, value);
+  }
+  const forwardedFor = forwardedForHeader(request);
+  if (forwardedFor) headers.set("X-Forwarded-For", forwardedFor);
+
   return fetch(upstreamUrl, {
     cache: "no-store",
+    headers,
     signal: request.signal,
   });
 }
@@ -0,0 +1,43 @@
+"use client";
+
+import { useImagesContext } from "@/contexts/imagesContext";
+import { useState } from "react";
+
+// 作品フォームの中でも使うため、formではなくEnterキーとボタンで検索する
+export default function ImageFilterBar() {
+  const { filter, setFilter } = useImagesContext();
+  const [inputQuery, setInputQuery] = useState(filter.q);
+
+  const applyQuery = () => setFilter({ ...filter, q: inputQuery });
+
+  return (
+    <div className="flex flex-wrap items-center gap-4 text-sm">
+      <input
+        value={inputQuery}
+        onChange={(e) => setInputQuery(e.target.value)}
+        onKeyDown={(e) => {
+          if (e.key !== "Enter") return;
+          e.preventDefault();
+          applyQuery();
+        }}
+        placeholder="ファイル名で検索"
+        className="min-w-48 flex-1 border-b-2 border-[#c68ef0] bg-white px-2 py-1 outline-none focus:border-[#7e11d1]"
+      />
+      <button
+        type="button"
+        onClick={applyQuery}
+        className="cursor-pointer border-b leading-none font-bold text-[#7e11d1] transition-all duration-200 hover:text-[#c68ef0]"
+      >
+        検索
+      </button>
+      <label className="flex items-center gap-1 select-none">
+        <input
+          type="checkbox"
+          checked={filter.unused}
+          onChange={(e) => setFilter({ ...filter, unused: e.target.checked })}
+        />
+        未使用のみ
+      </label>
+    </div>
+  );
+}
@@ -4,6 +4,7 @@
 import { useImagesContext } from "@/contexts/imagesContext";
 import { useDragAndDropUploader } from "@/hooks/useDragAndDropUploader";
 import { useEffect, useState } from "react";
+import ImageFilterBar from "./imageFilterBar";
 
 type ImageSelectingBoxProps = {
   onChange: (ids: string[]) => void;
@@ -16,7 +17,8 @@ export default function ImageSelectingBox({
   multiple,
   initialSelectedIds,
 }: ImageSelectingBoxProps) {
-  const { images, refreshImages } = useImagesContext();
+  const { images, refreshImages, hasMore, isLoadingMore, loadMoreImages } =
+    useImagesContext();
   const { isDragging, dragProps, fileInputProps, openFileDialog } =
     useDragAndDropUploader({ onUploadSuccess: refreshImages });
   const [selectedIds, setSelectedIds] = useState<string[]>(
@@ -58,6 +60,7 @@ export default function ImageSelectingBox({
           <input type="file" className="hidden" {...fileInputProps} />
         </div>
       </div>
+      <ImageFilterBar />
       <div className="flex w-full flex-wrap gap-4">
         {images.map((image, imageIdx) => {
           const isSelected = selectedIds.includes(image.id);
@@ -94,13 +97,23 @@ export default function ImageSelectingBox({
               </div>
               <img
                 className="pointer-events-none relative size-24 object-contain"
-                src={`/api/images/${image.id}/raw`}
+                src={`/api/images/${image.id}/raw?variant=thumbnail`}
                 alt={image.file_name}
               />
             </button>
           );
         })}
       </div>
+      {hasMore && (
+        <button
+          type="button"
+          disabled={isLoadingMore}
+          onClick={loadMoreImages}
+          className="mx-auto cursor-pointer border-b text-sm leading-none font-bold text-[#7e11d1] transition-all duration-200 hover:text-[#c68ef0]"
+        >
+          {isLoadingMore ? "読み込み中" : "さらに読み込む"}
+        </button>
+      )}
     </div>
   );
 }
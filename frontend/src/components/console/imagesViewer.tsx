@@ -2,12 +2,22 @@
 "use client";
 
 import { useImagesContext } from "@/contexts/imagesContext";
+import ImageFilterBar from "./imageFilterBar";
 import backendApi from "@/lib/auth/backendFetch";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
+import { ImageInUseResponse } from "@/types/images/common";
 import Link from "next/link";
 import { useCallback, useState } from "react";
 
 export default function ImagesViewer() {
-  const { images, error, refreshImages } = useImagesContext();
+  const {
+    images,
+    error,
+    refreshImages,
+    hasMore,
+    isLoadingMore,
+    loadMoreImages,
+  } = useImagesContext();
   const [deletingImageId, setDeletingImageId] = useState<string | null>(null);
 
   const handleDelete = useCallback(
@@ -19,12 +29,29 @@ export default function ImagesViewer() {
 
       setDeletingImageId(imageId);
       try {
-        const response = await backendApi(`/images/${imageId}`, {
+        let response = await backendApi(`/images/${imageId}`, {
           method: "DELETE",
         });
 
+        // 作品から使われている場合は、外してから削除するか確認する
+        if (response.status === 409) {
+          const { details } = (await response.json()) as ImageInUseResponse;
+          const titles = (details?.works ?? [])
+            .map((work) => `・${work.title}`)
+            .join("\n");
+          const shouldForce = window.confirm(
+            `次の作品で使われています。作品から外して削除しますか？\n${titles}`,
+          );
+          if (!shouldForce) {
+            return;
+          }
+          response = await backendApi(`/images/${imageId}?force=true`, {
+            method: "DELETE",
+          });
+        }
+
         if (!response.ok) {
-          const message = await response.text();
+          const message = formatErrorResponse(await response.text());
           throw new Error(message || "削除に失敗しました");
         }
 
@@ -37,11 +64,12 @@ export default function ImagesViewer() {
   );
 
   return (
-    <section className="bg-[#f8f8f8] px-4 py-4">
-      <div className="mb-4 flex items-center gap-4">
+    <section className="flex flex-col gap-4 bg-[#f8f8f8] px-4 py-4">
+      <div className="flex items-center gap-4">
         <p className="font-semibold text-[#7e11d1]">登録済み画像</p>
         {error && <span className="text-sm text-[#e04787]">{error}</span>}
       </div>
+      <ImageFilterBar />
       <div className="flex flex-wrap justify-center gap-4">
         {images.map((image, imageIdx) => {
           const isDeleting = deletingImageId === image.id;
@@ -64,7 +92,7 @@ export default function ImagesViewer() {
                 rel="noopener"
               >
                 <img
-                  src={`/api/images/${image.id}/raw`}
+                  src={`/api/images/${image.id}/raw?variant=thumbnail`}
                   alt={image.file_name}
                   className="size-full object-contain"
                 />
@@ -73,6 +101,16 @@ export default function ImagesViewer() {
           );
         })}
       </div>
+      {hasMore && (
+        <button
+          type="button"
+          disabled={isLoadingMore}
+          onClick={loadMoreImages}
+          className="mx-auto cursor-pointer border-b text-sm leading-none font-bold text-[#7e11d1] transition-all duration-200 hover:text-[#c68ef0]"
+        >
+          {isLoadingMore ? "読み込み中" : "さらに読み込む"}
+        </button>
+      )}
     </section>
   );
 }
@@ -1,6 +1,7 @@
 "use client";
 
 import backendApi from "@/lib/auth/backendFetch";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
 import { useCallback, useState } from "react";
 import { LabelText } from "./labelBlock";
 import { useTechsContext } from "@/contexts/techsContext";
@@ -56,7 +57,7 @@ export default function StackSelectingBox({
       });
 
       if (!response.ok) {
-        const message = await response.text();
+        const message = formatErrorResponse(await response.text());
         throw new Error(message || "登録に失敗しました");
       }
 
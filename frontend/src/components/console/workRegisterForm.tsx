@@ -1,6 +1,7 @@
 "use client";
 
 import backendApi from "@/lib/auth/backendFetch";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
 import React, {
   useCallback,
   useEffect,
@@ -176,7 +177,8 @@ export default function WorkRegisterForm({
         if (!response.ok) {
           const message = await response.text();
           throw new Error(
-            message ||
+            formatErrorResponse(message) ||
+              message ||
               (isEditingMode ? "更新に失敗しました" : "登録に失敗しました"),
           );
         }
@@ -2,6 +2,7 @@
 
 import { useWorksContext } from "@/contexts/worksContext";
 import backendApi from "@/lib/auth/backendFetch";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
 import { useCallback, useMemo, useState } from "react";
 import WorkRegisterForm from "./workRegisterForm";
 import { Work } from "@/types/works/common";
@@ -33,7 +34,8 @@ const initializeWorkValues = (work: Work) => {
 };
 
 export default function WorksViewer() {
-  const { works, refreshWorks } = useWorksContext();
+  const { works, refreshWorks, hasMore, isLoadingMore, loadMoreWorks } =
+    useWorksContext();
   const [editingWorkId, setEditingWorkId] = useState<string | null>(null);
   const [deletingWorkId, setDeletingWorkId] = useState<string | null>(null);
 
@@ -63,7 +65,7 @@ export default function WorksViewer() {
         });
 
         if (!response.ok) {
-          const message = await response.text();
+          const message = formatErrorResponse(await response.text());
           throw new Error(message || "削除に失敗しました");
         }
 
@@ -128,6 +130,15 @@ export default function WorksViewer() {
           );
         })}
       </ul>
+      {hasMore && (
+        <button
+          disabled={isLoadingMore}
+          onClick={loadMoreWorks}
+          className="cursor-pointer border-b text-sm leading-none font-bold text-[#7e11d1] transition-all duration-200 hover:text-[#c68ef0]"
+        >
+          {isLoadingMore ? "読み込み中" : "さらに読み込む"}
+        </button>
+      )}
     </div>
   );
 }
@@ -15,7 +15,7 @@ import WorkCard from "./workCard";
 import { useTechsContext } from "@/contexts/techsContext";
 
 export default function WorksList() {
-  const { works } = useWorksContext();
+  const { works, hasMore, isLoadingMore, loadMoreWorks } = useWorksContext();
   const { techs } = useTechsContext();
   const {
     visibleWorks,
@@ -191,6 +191,15 @@ export default function WorksList() {
             />
           ))}
         </div>
+        {hasMore && (
+          <button
+            className="relative z-10 mt-24 scale-100 cursor-pointer rounded-full border border-[#6354EB] bg-white px-4 py-1 text-base font-bold text-[#6354EB] transition-[background-color,scale] duration-[250ms,500ms] ease-[linear,cubic-bezier(0.34,1.56,0.64,1)] hover:scale-110 hover:bg-[#e4e0ff] disabled:pointer-events-none disabled:opacity-50"
+            disabled={isLoadingMore}
+            onClick={loadMoreWorks}
+          >
+            {isLoadingMore ? "Loading..." : "More Works"}
+          </button>
+        )}
       </div>
       <SelectedDetailScreen
         selectingWorkId={selectingWorkId}
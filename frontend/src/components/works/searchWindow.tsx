@@ -4,6 +4,7 @@
 import { useSelectingCubeContext } from "@/contexts/selectingCubeContext";
 import { useScrollbarControl } from "@/hooks/useScrollbarControl";
 import { Work } from "@/types/works/common";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
 import { useEffect, useRef, useState } from "react";
 import SearchIcon from "../public/searchIcon";
 import React from "react";
@@ -33,7 +34,7 @@ function WorkSearchResultItem({
       }}
     >
       <img
-        src={`/api/images/${work.thumbnail_image_id}/raw`}
+        src={`/api/images/${work.thumbnail_image_id}/raw?variant=thumbnail`}
         className={`pointer-events-none size-16 rounded object-cover transition-all select-none`}
         alt={`${work.title}検索サムネイル`}
         loading="lazy"
@@ -116,7 +117,9 @@ export default function SearchWindow() {
             signal: abortController.signal,
           });
           if (!response.ok) {
-            const message = (await response.text()) || "検索に失敗しました";
+            const message =
+              formatErrorResponse(await response.text()) ||
+              "検索に失敗しました";
             throw new Error(message);
           }
           const results = (await response.json()) as Work[];
@@ -156,7 +159,8 @@ export default function SearchWindow() {
         const response = await fetch(url.toString());
         if (!response.ok) {
           const message =
-            (await response.text()) || "ランキングの取得に失敗しました";
+            formatErrorResponse(await response.text()) ||
+            "ランキングの取得に失敗しました";
           throw new Error(message);
         }
         const results = (await response.json()) as Work[];
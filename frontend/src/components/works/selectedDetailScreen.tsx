@@ -158,7 +158,9 @@ export default function SelectedDetailScreen({
                   <div key={imageIdx} className="my-5">
                     <img
                       src={`/api/images/${imageId.image_id}/raw`}
-                      alt={`選択中の制作物画像${imageIdx + 1}`}
+                      alt={
+                        imageId.alt_text ?? `選択中の制作物画像${imageIdx + 1}`
+                      }
                       className="mx-auto max-h-[60vh] object-contain select-none"
                     />
                   </div>
@@ -1,6 +1,7 @@
 "use client";
 
-import { CommonImage } from "@/types/images/common";
+import backendApi from "@/lib/auth/backendFetch";
+import { CommonImage, ImagePage } from "@/types/images/common";
 import React, {
   createContext,
   useCallback,
@@ -10,28 +11,72 @@ import React, {
   useState,
 } from "react";
 
+const IMAGES_PAGE_SIZE = 50;
+
+export type ImageFilter = {
+  q: string;
+  unused: boolean;
+};
+
 type ImagesContextValue = {
   images: CommonImage[];
   isLoading: boolean;
+  isLoadingMore: boolean;
+  hasMore: boolean;
   error: string | null;
+  filter: ImageFilter;
+  setFilter: (filter: ImageFilter) => void;
   refreshImages: () => Promise<void>;
+  loadMoreImages: () => Promise<void>;
 };
 
+// admin: 管理画面用の画像ライブラリ (/admin/images) から、絞り込み条件付きで取得する
+type ImagesSource = "public" | "admin";
+
 const ImagesContext = createContext<ImagesContextValue | null>(null);
 
-export function ImagesProvider({ children }: { children: React.ReactNode }) {
+async function fetchImagesPage(
+  source: ImagesSource,
+  filter: ImageFilter,
+  cursor: string | null,
+): Promise<ImagePage> {
+  const params = new URLSearchParams({ limit: String(IMAGES_PAGE_SIZE) });
+  if (cursor) params.set("cursor", cursor);
+  if (filter.q.trim()) params.set("q", filter.q.trim());
+  if (source === "admin" && filter.unused) params.set("unused", "true");
+  const response =
+    source === "admin"
+      ? await backendApi(`/admin/images?${params.toString()}`)
+      : await fetch(`/api/images?${params.toString()}`);
+  if (!response.ok) throw new Error("画像の取得に失敗しました");
+  const page = (await response.json()) as ImagePage;
+  return {
+    items: Array.isArray(page.items) ? page.items : [],
+    next_cursor: page.next_cursor,
+  };
+}
+
+export function ImagesProvider({
+  children,
+  source = "public",
+}: {
+  children: React.ReactNode;
+  source?: ImagesSource;
+}) {
   const [images, setImages] = useState<CommonImage[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [filter, setFilter] = useState<ImageFilter>({ q: "", unused: false });
   const [isLoading, setIsLoading] = useState(true);
+  const [isLoadingMore, setIsLoadingMore] = useState(false);
   const [error, setError] = useState<string | null>(null);
 
   const fetchImages = useCallback(async () => {
     setIsLoading(true);
     setError(null);
     try {
-      const response = await fetch("/api/images");
-      if (!response.ok) throw new Error("画像の取得に失敗しました");
-      const parsedImages = (await response.json()) as CommonImage[];
-      setImages(parsedImages);
+      const page = await fetchImagesPage(source, filter, null);
+      setImages(page.items);
+      setNextCursor(page.next_cursor);
     } catch (error) {
       setError(
         error instanceof Error ? error.message : "画像の取得に失敗しました",
@@ -39,15 +84,52 @@ export function ImagesProvider({ children }: { children: React.ReactNode }) {
     } finally {
       setIsLoading(false);
     }
-  }, []);
+  }, [source, filter]);
+
+  // 続きのページは必要になったときだけ取得する
+  const loadMoreImages = useCallback(async () => {
+    if (!nextCursor || isLoadingMore) return;
+    setIsLoadingMore(true);
+    setError(null);
+    try {
+      const page = await fetchImagesPage(source, filter, nextCursor);
+      setImages((prev) => [...prev, ...page.items]);
+      setNextCursor(page.next_cursor);
+    } catch (error) {
+      setError(
+        error instanceof Error ? error.message : "画像の取得に失敗しました",
+      );
+    } finally {
+      setIsLoadingMore(false);
+    }
+  }, [source, filter, nextCursor, isLoadingMore]);
 
   useEffect(() => {
     fetchImages();
   }, [fetchImages]);
 
   const value = useMemo(
-    () => ({ images, isLoading, error, refreshImages: fetchImages }),
-    [images, isLoading, error, fetchImages],
+    () => ({
+      images,
+      isLoading,
+      isLoadingMore,
+      hasMore: nextCursor !== null,
+      error,
+      filter,
+      setFilter,
+      refreshImages: fetchImages,
+      loadMoreImages,
+    }),
+    [
+      images,
+      isLoading,
+      isLoadingMore,
+      nextCursor,
+      error,
+      filter,
+      fetchImages,
+      loadMoreImages,
+    ],
   );
 
   return (
@@ -1,6 +1,8 @@
 "use client";
 
-import { Work } from "@/types/works/common";
+import { Work, WorkPage } from "@/types/works/common";
+import backendApi from "@/lib/auth/backendFetch";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
 import React, {
   createContext,
   useCallback,
@@ -10,40 +12,72 @@ import React, {
   useState,
 } from "react";
 
+const WORKS_PAGE_SIZE = 20;
+
 type WorksContextValue = {
   works: Work[];
   isLoading: boolean;
+  isLoadingMore: boolean;
+  hasMore: boolean;
   error: string | null;
   refreshWorks: () => Promise<void>;
+  loadMoreWorks: () => Promise<void>;
 };
 
+// admin: 管理画面用。下書き・予約・限定公開の作品も含めて管理者トークンで取得する
+type WorksSource = "public" | "admin";
+
 const WorksContext = createContext<WorksContextValue | null>(null);
 
-export function WorksProvider({ children }: { children: React.ReactNode }) {
+async function fetchWorksPage(
+  source: WorksSource,
+  cursor: string | null,
+): Promise<WorkPage> {
+  const params = new URLSearchParams({ limit: String(WORKS_PAGE_SIZE) });
+  if (cursor) params.set("cursor", cursor);
+  const response =
+    source === "admin"
+      ? await backendApi(`/works/preview?${params.toString()}`)
+      : await fetch(`/api/works?${params.toString()}`);
+  if (!response.ok) {
+    const message =
+      formatErrorResponse(await response.text()) ||
+      "作品一覧の取得に失敗しました";
+    throw new Error(message);
+  }
+  const page = (await response.json()) as WorkPage;
+  const items = Array.isArray(page.items) ? page.items : [];
+  return {
+    items: items.map((work) => ({
+      ...work,
+      images: work.images ?? [],
+      urls: work.urls ?? [],
+      tech_stacks: work.tech_stacks ?? [],
+    })),
+    next_cursor: page.next_cursor,
+  };
+}
+
+export function WorksProvider({
+  children,
+  source = "public",
+}: {
+  children: React.ReactNode;
+  source?: WorksSource;
+}) {
   const [works, setWorks] = useState<Work[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
   const [isLoading, setIsLoading] = useState(true);
+  const [isLoadingMore, setIsLoadingMore] = useState(false);
   const [error, setError] = useState<string | null>(null);
 
   const refreshWorks = useCallback(async () => {
     setIsLoading(true);
     setError(null);
     try {
-      const response = await fetch("/api/works");
-      if (!response.ok) {
-        const message =
-          (await response.text()) || "作品一覧の取得に失敗しました";
-        throw new Error(message);
-      }
-      const parsedWorks = (await response.json()) as Work[];
-      const sanitizedWorks = Array.isArray(parsedWorks)
-        ? parsedWorks.map((work) => ({
-            ...work,
-            images: work.images ?? [],
-            urls: work.urls ?? [],
-            tech_stacks: work.tech_stacks ?? [],
-          }))
-        : [];
-      setWorks(sanitizedWorks);
+      const page = await fetchWorksPage(source, null);
+      setWorks(page.items);
+      setNextCursor(page.next_cursor);
     } catch (error) {
       setError(
         error instanceof Error ? error.message : "作品一覧の取得に失敗しました",
@@ -51,15 +85,49 @@ export function WorksProvider({ children }: { children: React.ReactNode }) {
     } finally {
       setIsLoading(false);
     }
-  }, []);
+  }, [source]);
+
+  // 続きのページは必要になったときだけ取得する
+  const loadMoreWorks = useCallback(async () => {
+    if (!nextCursor || isLoadingMore) return;
+    setIsLoadingMore(true);
+    setError(null);
+    try {
+      const page = await fetchWorksPage(source, nextCursor);
+      setWorks((prev) => [...prev, ...page.items]);
+      setNextCursor(page.next_cursor);
+    } catch (error) {
+      setError(
+        error instanceof Error ? error.message : "作品一覧の取得に失敗しました",
+      );
+    } finally {
+      setIsLoadingMore(false);
+    }
+  }, [source, nextCursor, isLoadingMore]);
 
   useEffect(() => {
     refreshWorks();
   }, [refreshWorks]);
 
   const value = useMemo(
-    () => ({ works, isLoading, error, refreshWorks }),
-    [works, isLoading, error, refreshWorks],
+    () => ({
+      works,
+      isLoading,
+      isLoadingMore,
+      hasMore: nextCursor !== null,
+      error,
+      refreshWorks,
+      loadMoreWorks,
+    }),
+    [
+      works,
+      isLoading,
+      isLoadingMore,
+      nextCursor,
+      error,
+      refreshWorks,
+      loadMoreWorks,
+    ],
   );
 
   return (
@@ -1,8 +1,13 @@
 "use client";
 
 import backendApi from "@/lib/auth/backendFetch";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
+import { BulkImageUploadResponse } from "@/types/images/common";
 import React, { useCallback, useRef, useState } from "react";
 
+// バックエンドが1リクエストで受け付けるファイル数の上限
+const BULK_UPLOAD_MAX_FILES = 50;
+
 export type FileUploadingState = {
   id: string;
   file: File;
@@ -35,37 +40,55 @@ export function useDragAndDropUploader({
     setIsDragging(false);
   }, []);
 
-  const uploadFile = useCallback(
-    async (id: string, file: File) => {
+  const uploadFiles = useCallback(
+    async (targets: FileUploadingState[]) => {
+      const ids = new Set(targets.map(({ id }) => id));
       setFileUploadingStates((prev) =>
         prev.map((state) =>
-          state.id === id ? { ...state, status: "uploading" } : state,
+          ids.has(state.id) ? { ...state, status: "uploading" } : state,
         ),
       );
 
       const formData = new FormData();
-      formData.append("file", file);
+      targets.forEach(({ file }) => formData.append("file", file));
 
       try {
-        const response = await backendApi("/images", {
+        const response = await backendApi("/images/bulk", {
           method: "POST",
           body: formData,
         });
 
         if (!response.ok) {
-          throw new Error("アップロードに失敗");
+          throw new Error(
+            formatErrorResponse(await response.text()) || "アップロードに失敗",
+          );
         }
 
+        // 結果は送ったファイルと同じ順で返る
+        const { results } = (await response.json()) as BulkImageUploadResponse;
+        const resultById = new Map(
+          targets.map(({ id }, index) => [id, results[index]]),
+        );
         setFileUploadingStates((prev) =>
-          prev.map((state) =>
-            state.id === id ? { ...state, status: "success" } : state,
-          ),
+          prev.map((state) => {
+            if (!resultById.has(state.id)) return state;
+            const result = resultById.get(state.id);
+            return result?.image
+              ? { ...state, status: "success" }
+              : {
+                  ...state,
+                  status: "error",
+                  errorMessage: result?.error?.message ?? "アップロードに失敗",
+                };
+          }),
         );
-        await onUploadSuccess?.();
+        if (results.some((result) => result.image)) {
+          await onUploadSuccess?.();
+        }
       } catch (error) {
         setFileUploadingStates((prev) =>
           prev.map((state) =>
-            state.id === id
+            ids.has(state.id)
               ? {
                   ...state,
                   status: "error",
@@ -93,11 +116,11 @@ export function useDragAndDropUploader({
       );
 
       setFileUploadingStates((prev) => [...prev, ...nextStates]);
-      nextStates.forEach(({ id, file }) => {
-        uploadFile(id, file);
-      });
+      for (let i = 0; i < nextStates.length; i += BULK_UPLOAD_MAX_FILES) {
+        uploadFiles(nextStates.slice(i, i + BULK_UPLOAD_MAX_FILES));
+      }
     },
-    [uploadFile],
+    [uploadFiles],
   );
 
   const handleDrop = useCallback(
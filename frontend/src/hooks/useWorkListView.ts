@@ -1,6 +1,7 @@
 "use client";
 
 import type { Work } from "@/types/works/common";
+import { formatErrorResponse } from "@/lib/formatErrorResponse";
 import { useEffect, useMemo, useState } from "react";
 import { useWorkTechFilter } from "./useWorkTechFilter";
 
@@ -41,7 +42,8 @@ export function useWorkListView(works: Work[]) {
         const response = await fetch(url, { signal: abortController.signal });
         if (!response.ok) {
           throw new Error(
-            (await response.text()) || "人気順の取得に失敗しました",
+            formatErrorResponse(await response.text()) ||
+              "人気順の取得に失敗しました",
           );
         }
 
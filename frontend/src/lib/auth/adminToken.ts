@@ -0,0 +1,27 @@
+import { createHmac } from "crypto";
+
+const ADMIN_TOKEN_ISSUER = "nextjs-go-admin-portfolio-web";
+const ADMIN_TOKEN_AUDIENCE = "nextjs-go-admin-portfolio-backend";
+const ADMIN_TOKEN_LIFETIME_SECONDS = 60;
+
+const encodeBase64Url = (value: string) =>
+  Buffer.from(value).toString("base64url");
+
+export const signAdminToken = (email: string, secret: string) => {
+  const issuedAt = Math.floor(Date.now() / 1000);
+  const header = encodeBase64Url(JSON.stringify({ alg: "HS256", typ: "JWT" }));
+  const payload = encodeBase64Url(
+    JSON.stringify({
+      sub: email.trim().toLowerCase(),
+      iss: ADMIN_TOKEN_ISSUER,
+      aud: ADMIN_TOKEN_AUDIENCE,
+      iat: issuedAt,
+      exp: issuedAt + ADMIN_TOKEN_LIFETIME_SECONDS,
+    }),
+  );
+  const signature = createHmac("sha256", secret)
+    .update(`${header}.${payload}`)
+    .digest("base64url");
+
+  return `${header}.${payload}.${signature}`;
+};
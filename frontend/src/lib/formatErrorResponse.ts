@@ -0,0 +1,24 @@
+type FieldError = {
+  field: string;
+  code: string;
+};
+
+type ErrorResponse = {
+  code?: string;
+  message?: string;
+  request_id?: string;
+  details?: { errors?: FieldError[] } | null;
+};
+
+export const formatErrorResponse = (body: string): string | null => {
+  try {
+    const parsed = JSON.parse(body) as ErrorResponse;
+    const errors = parsed.details?.errors;
+    if (Array.isArray(errors) && errors.length) {
+      return errors.map((error) => `${error.field}: ${error.code}`).join(", ");
+    }
+    return parsed.message || null;
+  } catch {
+    return null;
+  }
+};
@@ -0,0 +1,6 @@
+// バックエンドがNext.jsのサーバーではなく閲覧者ごとに制限をかけられるよう、接続元を引き継ぐ
+export function forwardedForHeader(request: Request): string | null {
+  const forwardedFor = request.headers.get("x-forwarded-for");
+  if (forwardedFor) return forwardedFor;
+  return request.headers.get("x-real-ip");
+}
@@ -5,4 +5,60 @@ export type CommonImage = {
   mime_type: string;
   file_size: number;
   uploaded_at: string;
+  width: number | null;
+  height: number | null;
+  dominant_color: string | null;
+  content_hash: string | null;
+  alt_text: string | null;
+  caption: string | null;
+  credit: string | null;
+  duplicate_of_image_id: string | null;
+};
+
+export type ImagePage = {
+  items: CommonImage[];
+  next_cursor: string | null;
+};
+
+export type ImageUsageWork = {
+  id: string;
+  title: string;
+  status: string;
+  trashed: boolean;
+};
+
+export type LibraryImage = CommonImage & {
+  usage_count: number;
+  used_by: ImageUsageWork[];
+};
+
+export type LibraryImagePage = {
+  items: LibraryImage[];
+  next_cursor: string | null;
+};
+
+export type BulkImageUploadResult = {
+  file_name: string;
+  image: CommonImage | null;
+  deduplicated: boolean;
+  error: { code: string; message: string } | null;
+};
+
+export type BulkImageUploadResponse = {
+  results: BulkImageUploadResult[];
+};
+
+export type ImageReference = {
+  work_id: string;
+  title: string;
+  status: string;
+  trashed: boolean;
+  thumbnail: boolean;
+  gallery_images: number;
+};
+
+export type ImageInUseResponse = {
+  code: string;
+  message: string;
+  details: { works?: ImageReference[] } | null;
 };
@@ -4,6 +4,7 @@ export type WorkImage = {
   id: string;
   image_id: string;
   display_order: number;
+  alt_text: string | null;
 };
 
 export type WorkUrl = {
@@ -15,15 +16,28 @@ export type WorkUrl = {
 
 export type WorkTechStack = CommonTechStack;
 
+export type WorkStatus = "draft" | "published" | "scheduled" | "unlisted";
+
 export type Work = {
   id: string;
+  slug: string | null;
   title: string;
   comment: string;
   created_at: string;
+  status: WorkStatus;
+  publish_at: string | null;
+  updated_at: string;
+  version: number;
   accent_color: string;
   description: string | null;
   thumbnail_image_id: string | null;
   images: WorkImage[];
   urls: WorkUrl[];
   tech_stacks: WorkTechStack[];
+  click_count?: number;
+};
+
+export type WorkPage = {
+  items: Work[];
+  next_cursor: string | null;
 };